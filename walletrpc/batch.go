@@ -0,0 +1,344 @@
+package walletrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrBatchNotSent is set on the calls of a Batch that failed as a whole
+// before the request left the client. None of the calls ran.
+var ErrBatchNotSent = errors.New("walletrpc: batch was not sent")
+
+// ErrBatchOutcomeUnknown is set on the calls of a Batch that failed as a
+// whole after the request may have reached the wallet. Each call may or may
+// not have run.
+var ErrBatchOutcomeUnknown = errors.New("walletrpc: batch outcome is unknown")
+
+// ErrBatchUnsupported is returned by SendContext when the server rejected a
+// batch holding non-idempotent calls (see ClassifyMethod). The server may
+// have run some of them before answering, so they are not sent again one
+// at a time, and their outcome is ErrBatchOutcomeUnknown. Later batches are
+// sent one call at a time from the start.
+var ErrBatchUnsupported = errors.New("walletrpc: the server does not accept batch requests")
+
+// BatchCall is a single call queued in a Batch. Result and Error are
+// populated after the batch is sent.
+type BatchCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+	Error  error
+}
+
+// Batch queues several calls and sends them to monero-wallet-rpc as a
+// single JSON-RPC 2.0 batch request. If the server does not accept
+// batches, the calls are sent one at a time.
+type Batch struct {
+	c     *client
+	calls []*BatchCall
+	// when set, sends the calls in place of c (see Pool)
+	route func(ctx context.Context, calls []*BatchCall) error
+}
+
+// NewBatch returns an empty batch bound to the client.
+func (c *client) NewBatch() *Batch {
+	return &Batch{
+		c: c,
+	}
+}
+
+// newRoutedBatch returns an empty batch whose calls are sent by route.
+func newRoutedBatch(route func(ctx context.Context, calls []*BatchCall) error) *Batch {
+	return &Batch{
+		route: route,
+	}
+}
+
+// Add queues a call. result must be a pointer (or nil if the call does not
+// return anything).
+func (b *Batch) Add(method string, params, result interface{}) *BatchCall {
+	call := &BatchCall{
+		Method: method,
+		Params: params,
+		Result: result,
+	}
+	b.calls = append(b.calls, call)
+	return call
+}
+
+// Len returns the number of queued calls.
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// GetBalance queues a getbalance call.
+func (b *Batch) GetBalance(balance, unlockedBalance *uint64) *BatchCall {
+	return b.Add("getbalance", nil, &struct {
+		Balance         *uint64 `json:"balance"`
+		UnlockedBalance *uint64 `json:"unlocked_balance"`
+	}{balance, unlockedBalance})
+}
+
+// GetAddress queues a getaddress call.
+func (b *Batch) GetAddress(address *string) *BatchCall {
+	return b.Add("getaddress", nil, &struct {
+		Address *string `json:"address"`
+	}{address})
+}
+
+// GetHeight queues a getheight call.
+func (b *Batch) GetHeight(height *uint64) *BatchCall {
+	return b.Add("getheight", nil, &struct {
+		Height *uint64 `json:"height"`
+	}{height})
+}
+
+// GetPayments queues a get_payments call.
+func (b *Batch) GetPayments(paymentid string, payments *[]Payment) *BatchCall {
+	return b.Add("get_payments", &struct {
+		PaymentID string `json:"payment_id"`
+	}{paymentid}, &struct {
+		Payments *[]Payment `json:"payments"`
+	}{payments})
+}
+
+// GetTransferByTxID queues a get_transfer_by_txid call.
+func (b *Batch) GetTransferByTxID(txid string, transfer *Transfer) *BatchCall {
+	return b.Add("get_transfer_by_txid", &struct {
+		TxID string `json:"txid"`
+	}{txid}, &struct {
+		Transfer *Transfer `json:"transfer"`
+	}{transfer})
+}
+
+// GetTxNotes queues a get_tx_notes call.
+func (b *Batch) GetTxNotes(txids []string, notes *[]string) *BatchCall {
+	return b.Add("get_tx_notes", &struct {
+		TxIDs []string `json:"txids"`
+	}{txids}, &struct {
+		Notes *[]string `json:"notes"`
+	}{notes})
+}
+
+// Send is SendContext with context.Background().
+func (b *Batch) Send() error {
+	return b.SendContext(context.Background())
+}
+
+// SendContext sends every queued call. The returned error is only set when
+// the batch failed as a whole, in which case the Error of each call is
+// ErrBatchNotSent, ErrBatchOutcomeUnknown, or the same error when the
+// wallet answered with an unusable response (an HTTP error,
+// ErrResponseTooLarge...); otherwise the outcome of each call is in its
+// Error field.
+//
+// Each call goes through the interceptors of the client (and its Logger)
+// like a call made on its own. The calls reaching the end of the chain are
+// sent together with ctx, whatever context the interceptors pass on, and
+// next returns once the whole batch is done. A call that an interceptor
+// repeats after that is sent on its own.
+func (b *Batch) SendContext(ctx context.Context) error {
+	if len(b.calls) == 0 {
+		return nil
+	}
+	if b.route != nil {
+		return b.route(ctx, b.calls)
+	}
+	if len(b.c.interceptors) == 0 {
+		return b.send(ctx, b.calls)
+	}
+	return b.intercept(ctx)
+}
+
+// intercept runs every call through the interceptors concurrently, and
+// sends the calls that reached the end of the chain once every call
+// reached it or was short-circuited.
+func (b *Batch) intercept(ctx context.Context) error {
+	var (
+		mu      sync.Mutex
+		started bool
+		pending []*BatchCall
+		arrived sync.WaitGroup
+		done    sync.WaitGroup
+		sent    = make(chan struct{})
+	)
+	arrived.Add(len(b.calls))
+	done.Add(len(b.calls))
+	for _, call := range b.calls {
+		once := new(sync.Once)
+		final := func(ctx context.Context, method string, params, result interface{}) error {
+			inner := &BatchCall{Method: method, Params: params, Result: result}
+			mu.Lock()
+			late := started
+			if !late {
+				pending = append(pending, inner)
+			}
+			mu.Unlock()
+			if late {
+				return b.c.call(ctx, method, params, result)
+			}
+			once.Do(arrived.Done)
+			<-sent
+			return inner.Error
+		}
+		go func(call *BatchCall, invoke Invoker) {
+			defer done.Done()
+			call.Error = invoke(ctx, call.Method, call.Params, call.Result)
+			// the call was short-circuited, or is done
+			once.Do(arrived.Done)
+		}(call, chainInvoker(b.c.interceptors, final))
+	}
+	arrived.Wait()
+	mu.Lock()
+	started = true
+	mu.Unlock()
+	var err error
+	if len(pending) > 0 {
+		err = b.send(ctx, pending)
+	}
+	close(sent)
+	done.Wait()
+	return err
+}
+
+// send sends calls in a single batch request, or one at a time when the
+// server does not accept batches.
+func (b *Batch) send(ctx context.Context, calls []*BatchCall) error {
+	if atomic.LoadInt32(&b.c.nobatch) == 1 {
+		b.sendSequential(ctx, calls)
+		return nil
+	}
+	state, err := b.sendBatch(ctx, calls)
+	if err == ErrBatchUnsupported {
+		atomic.StoreInt32(&b.c.nobatch, 1)
+		if !b.nonIdempotent(calls) {
+			b.sendSequential(ctx, calls)
+			return nil
+		}
+	}
+	if err != nil {
+		callErr := ErrBatchOutcomeUnknown
+		switch state {
+		case attemptNotSent:
+			callErr = ErrBatchNotSent
+		case attemptResponded:
+			if err != ErrBatchUnsupported {
+				// what each call gets on its own
+				callErr = err
+			}
+		}
+		for _, call := range calls {
+			call.Error = callErr
+		}
+	}
+	return err
+}
+
+// nonIdempotent tells if calls hold a call that must not run twice.
+func (b *Batch) nonIdempotent(calls []*BatchCall) bool {
+	for _, call := range calls {
+		if b.c.retry.classify(call.Method) == MethodNonIdempotent {
+			return true
+		}
+	}
+	return false
+}
+
+// sendSequential sends calls one at a time. They already went through the
+// interceptors.
+func (b *Batch) sendSequential(ctx context.Context, calls []*BatchCall) {
+	for _, call := range calls {
+		call.Error = b.c.call(ctx, call.Method, call.Params, call.Result)
+	}
+}
+
+func (b *Batch) sendBatch(ctx context.Context, calls []*BatchCall) (attemptState, error) {
+	if b.c.initErr != nil {
+		return attemptNotSent, b.c.initErr
+	}
+	reqs := make([]*rpcRequest, len(calls))
+	byid := make(map[uint64]*BatchCall, len(calls))
+	for i, call := range calls {
+		reqs[i] = newRPCRequest(call.Method, call.Params)
+		byid[reqs[i].ID] = call
+	}
+	payload, err := json.Marshal(reqs)
+	if err != nil {
+		return attemptNotSent, err
+	}
+	if q := b.c.queue; q != nil {
+		priority := math.MinInt32
+		for _, call := range calls {
+			if p := q.priority(ctx, call.Method); p > priority {
+				priority = p
+			}
+		}
+		if err := q.acquire(ctx, priority); err != nil {
+			return attemptNotSent, err
+		}
+		defer q.release()
+	}
+	req, err := http.NewRequest(http.MethodPost, b.c.addr, bytes.NewBuffer(payload))
+	if err != nil {
+		return attemptNotSent, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range b.c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := b.c.httpcl.Do(req)
+	if err != nil {
+		if isDialError(err) {
+			return attemptNotSent, err
+		}
+		return attemptSent, err
+	}
+	if resp.StatusCode == http.StatusBadRequest {
+		drainBody(resp.Body)
+		return attemptResponded, ErrBatchUnsupported
+	}
+	if resp.StatusCode != http.StatusOK {
+		return attemptResponded, newHTTPError(resp)
+	}
+	defer drainBody(resp.Body)
+	body, err := readBody(resp.Body, b.c.maxResponseSize)
+	if err != nil {
+		if err == ErrResponseTooLarge {
+			return attemptResponded, err
+		}
+		return attemptSent, err
+	}
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		// a single error object means the server rejected the array
+		return attemptResponded, ErrBatchUnsupported
+	}
+	var resps []rpcResponse
+	if err := json.Unmarshal(body, &resps); err != nil {
+		return attemptResponded, err
+	}
+	for i := range resps {
+		id, _ := resps[i].id()
+		call, ok := byid[id]
+		if !ok {
+			continue
+		}
+		delete(byid, id)
+		if err := resps[i].validate(id); err != nil {
+			call.Error = err
+			continue
+		}
+		call.Error = resps[i].decodeResult(call.Method, call.Result, b.c.strict)
+	}
+	for id, call := range byid {
+		call.Error = fmt.Errorf("walletrpc: no response for batch request id %v", id)
+	}
+	return attemptResponded, nil
+}
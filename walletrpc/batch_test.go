@@ -0,0 +1,352 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func batchTestHandler(t *testing.T, method string, params json.RawMessage) interface{} {
+	switch method {
+	case "getheight":
+		return H{"height": 99}
+	case "get_tx_notes":
+		var jin struct {
+			TxIDs []string `json:"txids"`
+		}
+		assert.NoError(t, json.Unmarshal(params, &jin))
+		notes := make([]string, len(jin.TxIDs))
+		for i, v := range jin.TxIDs {
+			notes[i] = "note " + v
+		}
+		return H{"notes": notes}
+	}
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	var posts int
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		var reqs []struct {
+			ID     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&reqs))
+		resps := make([]H, 0, len(reqs))
+		// reply in reverse order to exercise id matching
+		for i := len(reqs) - 1; i >= 0; i-- {
+			result := batchTestHandler(t, reqs[i].Method, reqs[i].Params)
+			if result == nil {
+				resps = append(resps, H{"jsonrpc": "2.0", "id": reqs[i].ID, "error": H{"code": ErrWrongTxID, "message": "bad"}})
+				continue
+			}
+			resps = append(resps, H{"jsonrpc": "2.0", "id": reqs[i].ID, "result": result})
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	var height uint64
+	var notes []string
+	var transfer Transfer
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.GetTxNotes([]string{"a", "b"}, &notes)
+	c2 := b.GetTransferByTxID("c", &transfer)
+	assert.NoError(t, b.Send())
+	assert.Equal(t, 1, posts)
+	assert.NoError(t, c0.Error)
+	assert.NoError(t, c1.Error)
+	assert.Equal(t, uint64(99), height)
+	assert.Equal(t, []string{"note a", "note b"}, notes)
+	iswerr, werr := GetWalletError(c2.Error)
+	assert.True(t, iswerr)
+	assert.Equal(t, ErrWrongTxID, werr.Code)
+}
+
+func TestBatchFallback(t *testing.T) {
+	var posts int
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			posts++
+			var raw json.RawMessage
+			if params != nil {
+				raw = *params
+			}
+			writerpcResponseOK(batchTestHandler(t, method, raw), w)
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	var height uint64
+	var notes []string
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.GetTxNotes([]string{"a"}, &notes)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.NoError(t, c1.Error)
+	assert.Equal(t, uint64(99), height)
+	assert.Equal(t, []string{"note a"}, notes)
+	// one rejected batch + two sequential calls
+	assert.Equal(t, 2, posts)
+}
+
+func TestBatchNotSent(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: "http://" + addr + "/json_rpc",
+	})
+	var height uint64
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	assert.Error(t, b.Send())
+	assert.Equal(t, ErrBatchNotSent, c0.Error)
+}
+
+func TestBatchOutcomeUnknown(t *testing.T) {
+	var posts int32
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		ioutil.ReadAll(r.Body)
+		// the wallet ran the calls, but the connection dropped
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	var height uint64
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.Add("transfer", TransferRequest{}, &TransferResponse{})
+	assert.Error(t, b.Send())
+	assert.Equal(t, ErrBatchOutcomeUnknown, c0.Error)
+	assert.Equal(t, ErrBatchOutcomeUnknown, c1.Error)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+}
+
+func TestBatchResponseTooLarge(t *testing.T) {
+	var posts int32
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		ioutil.ReadAll(r.Body)
+		w.Write([]byte(`[{"jsonrpc":"2.0","id":1,"result":{"height":1}}]`))
+	}))
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address:         sv0.URL + "/json_rpc",
+		MaxResponseSize: 16,
+		Retry:           &RetryPolicy{MaxAttempts: 3},
+	})
+	var height uint64
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.Add("transfer", TransferRequest{}, &TransferResponse{})
+	assert.Equal(t, ErrResponseTooLarge, b.Send())
+	assert.Equal(t, ErrResponseTooLarge, c0.Error)
+	assert.Equal(t, ErrResponseTooLarge, c1.Error)
+
+	// like the same reply to a call on its own
+	_, err := rpccl.GetHeight()
+	assert.Equal(t, ErrResponseTooLarge, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&posts))
+}
+
+func TestBatchFallbackNonIdempotent(t *testing.T) {
+	var posts int32
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			atomic.AddInt32(&posts, 1)
+			switch method {
+			case "getheight":
+				writerpcResponseOK(H{"height": 99}, w)
+			case "transfer":
+				writerpcResponseOK(H{"tx_hash": "abc"}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	var height uint64
+	var tr TransferResponse
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.Add("transfer", TransferRequest{}, &tr)
+	assert.Equal(t, ErrBatchUnsupported, b.Send())
+	assert.Equal(t, ErrBatchOutcomeUnknown, c0.Error)
+	assert.Equal(t, ErrBatchOutcomeUnknown, c1.Error)
+	// no call was resent one at a time
+	assert.Equal(t, int32(0), atomic.LoadInt32(&posts))
+
+	// the server is known not to accept batches, so nothing is sent twice
+	b = rpccl.NewBatch()
+	c0 = b.GetHeight(&height)
+	c1 = b.Add("transfer", TransferRequest{}, &tr)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.NoError(t, c1.Error)
+	assert.Equal(t, "abc", tr.TxHash)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&posts))
+}
+
+func TestBatchInterceptors(t *testing.T) {
+	var posts int32
+	var sent []string
+	var mu sync.Mutex
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+		var reqs []struct {
+			ID     uint64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&reqs))
+		resps := make([]H, 0, len(reqs))
+		for _, req := range reqs {
+			mu.Lock()
+			sent = append(sent, req.Method)
+			mu.Unlock()
+			result := batchTestHandler(t, req.Method, req.Params)
+			if result == nil {
+				result = H{"key": "secret key"}
+			}
+			resps = append(resps, H{"jsonrpc": "2.0", "id": req.ID, "result": result})
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer sv0.Close()
+
+	errDenied := errors.New("denied by policy")
+	var seen []string
+	var entries []*LogEntry
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+		Logger: LoggerFunc(func(entry *LogEntry) {
+			mu.Lock()
+			entries = append(entries, entry)
+			mu.Unlock()
+		}),
+		Interceptors: []Interceptor{
+			func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+				mu.Lock()
+				seen = append(seen, method)
+				mu.Unlock()
+				switch method {
+				case "transfer":
+					return errDenied
+				case "get_tx_notes":
+					params = H{"txids": []string{"changed"}}
+				}
+				return next(ctx, method, params, result)
+			},
+		},
+	})
+	var height uint64
+	var notes []string
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	c1 := b.GetTxNotes([]string{"a"}, &notes)
+	c2 := b.Add("transfer", TransferRequest{}, nil)
+	c3 := b.Add("query_key", H{"key_type": "spend_key"}, &struct {
+		Key string `json:"key"`
+	}{})
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.NoError(t, c1.Error)
+	assert.Equal(t, errDenied, c2.Error)
+	assert.NoError(t, c3.Error)
+	assert.Equal(t, uint64(99), height)
+	assert.Equal(t, []string{"note changed"}, notes)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+	sort.Strings(sent)
+	sort.Strings(seen)
+	assert.Equal(t, []string{"get_tx_notes", "getheight", "query_key"}, sent)
+	assert.Equal(t, []string{"get_tx_notes", "getheight", "query_key", "transfer"}, seen)
+	if assert.Len(t, entries, 4) {
+		for _, e := range entries {
+			buf, _ := json.Marshal(e)
+			assert.NotContains(t, string(buf), "secret")
+		}
+	}
+}
+
+func TestBatchInterceptorRepeat(t *testing.T) {
+	var posts int32
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&posts, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID uint64 `json:"id"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		json.NewEncoder(w).Encode(H{"jsonrpc": "2.0", "id": req.ID, "result": H{"height": 99}})
+	}))
+	defer sv0.Close()
+
+	// the first attempt fails with the batch, the second is sent alone
+	var attempts int32
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+		Interceptors: []Interceptor{
+			func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+				var err error
+				for i := 0; i < 2; i++ {
+					atomic.AddInt32(&attempts, 1)
+					if err = next(ctx, method, params, result); err == nil {
+						break
+					}
+				}
+				return err
+			},
+		},
+	})
+	var height uint64
+	b := rpccl.NewBatch()
+	c0 := b.GetHeight(&height)
+	var herr *HTTPError
+	assert.True(t, errors.As(b.Send(), &herr))
+	assert.NoError(t, c0.Error)
+	assert.Equal(t, uint64(99), height)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&posts))
+}
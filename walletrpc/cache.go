@@ -0,0 +1,282 @@
+package walletrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTLs are the TTLs used by NewCachedClient when
+// CacheConfig.TTLs is nil.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"getaddress":    time.Minute,
+	"getbalance":    10 * time.Second,
+	"getheight":     10 * time.Second,
+	"get_languages": time.Hour,
+}
+
+// CacheConfig configures a CachedClient.
+type CacheConfig struct {
+	// TTLs maps monero-wallet-rpc method names ("getbalance", "getheight"...)
+	// to how long their results are cached. Methods that are not listed
+	// are not cached. Defaults to DefaultCacheTTLs.
+	TTLs map[string]time.Duration
+	// MaxEntries caps the number of cached results. Defaults to 1024.
+	MaxEntries int
+}
+
+// CacheStats reports the activity of a CachedClient.
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Invalidations uint64
+	Entries       int
+	// Methods holds the hits and misses of each cached method.
+	Methods map[string]CacheMethodStats
+}
+
+// CacheMethodStats reports the hits and misses of a method.
+type CacheMethodStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CachedClient is a ClientContext that caches the results of read-only
+// methods for a configurable time. Calling a method that changes the wallet
+// state (Transfer, SweepAll, OpenWallet, RescanBlockchain...) through the
+// CachedClient clears the cache. Changes made by other clients of the same
+// wallet are only seen after the TTLs expire. Streams are never cached.
+//
+// Every method is forwarded to the wrapped client with CallContext, or Call
+// when it is not a ClientContext, and the methods that are not read-only
+// (see ClassifyMethod) clear the cache.
+type CachedClient struct {
+	*client
+	next       Client
+	ttls       map[string]time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	gen     uint64
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+var _ ClientContext = (*CachedClient)(nil)
+
+// NewCachedClient returns a CachedClient that forwards calls to c. When c is
+// a ClientContext, the contexts are passed on; otherwise a cancelled context
+// only fails the calls that did not start yet.
+func NewCachedClient(c Client, cfg CacheConfig) *CachedClient {
+	cc := &CachedClient{
+		next:       c,
+		ttls:       cfg.TTLs,
+		maxEntries: cfg.MaxEntries,
+		entries:    make(map[string]cacheEntry),
+	}
+	if cc.ttls == nil {
+		cc.ttls = DefaultCacheTTLs
+	}
+	if cc.maxEntries <= 0 {
+		cc.maxEntries = 1024
+	}
+	cc.stats.Methods = make(map[string]CacheMethodStats)
+	cc.client = &client{
+		invoke: cc.route,
+	}
+	return cc
+}
+
+// Stats returns the cache statistics.
+func (c *CachedClient) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	st.Methods = make(map[string]CacheMethodStats, len(c.stats.Methods))
+	for k, v := range c.stats.Methods {
+		st.Methods[k] = v
+	}
+	return st
+}
+
+// Invalidate clears the cache.
+func (c *CachedClient) Invalidate() {
+	c.mu.Lock()
+	c.invalidateLocked()
+	c.mu.Unlock()
+}
+
+func (c *CachedClient) invalidateLocked() {
+	c.clearLocked()
+	c.stats.Invalidations++
+}
+
+// clearLocked drops the entries and the results being fetched.
+func (c *CachedClient) clearLocked() {
+	c.gen++
+	c.entries = make(map[string]cacheEntry)
+}
+
+// cached decodes the cached result of method for params into out, or calls
+// fetch to fill out and caches it. Methods without a TTL are not cached.
+// Results are kept apart by the type of out, so a typed method and a Call
+// of the same method do not share their results.
+func (c *CachedClient) cached(method string, params, out interface{}, fetch func() error) error {
+	ttl := c.ttls[method]
+	if ttl <= 0 {
+		return fetch()
+	}
+	kbuf, err := json.Marshal(params)
+	if err != nil {
+		return fetch()
+	}
+	key := fmt.Sprintf("%v %T %s", method, out, kbuf)
+
+	c.mu.Lock()
+	ms := c.stats.Methods[method]
+	if e, ok := c.entries[key]; ok && time.Now().Before(e.expires) {
+		c.stats.Hits++
+		ms.Hits++
+		c.stats.Methods[method] = ms
+		c.mu.Unlock()
+		return json.Unmarshal(e.value, out)
+	}
+	c.stats.Misses++
+	ms.Misses++
+	c.stats.Methods[method] = ms
+	gen := c.gen
+	c.mu.Unlock()
+
+	if err := fetch(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(out)
+	if err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		// the wallet state changed while fetching
+		return nil
+	}
+	if len(c.entries) >= c.maxEntries {
+		c.evictLocked()
+	}
+	c.entries[key] = cacheEntry{value, time.Now().Add(ttl)}
+	return nil
+}
+
+// evictLocked removes the expired entries or, if there are none, the entry
+// closest to expiring.
+func (c *CachedClient) evictLocked() {
+	now := time.Now()
+	var oldest string
+	var oldestExp time.Time
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldest == "" || e.expires.Before(oldestExp) {
+			oldest, oldestExp = k, e.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldest)
+	}
+}
+
+// changing is deferred by the methods that change the wallet state. The
+// cache is cleared before and after the call, so results fetched while it
+// runs are not stored, and it counts as a single invalidation.
+func (c *CachedClient) changing() func() {
+	c.mu.Lock()
+	c.clearLocked()
+	c.mu.Unlock()
+	return c.Invalidate
+}
+
+// route is the Invoker of the CachedClient.
+func (c *CachedClient) route(ctx context.Context, method string, params, result interface{}) error {
+	if sd, ok := result.(streamDecoder); ok {
+		return c.stream(ctx, method, params, sd)
+	}
+	if ClassifyMethod(method) != MethodReadOnly {
+		defer c.changing()()
+		return c.forward(ctx, method, params, result)
+	}
+	if result == nil {
+		return c.forward(ctx, method, params, nil)
+	}
+	return c.cached(method, params, result, func() error {
+		return c.forward(ctx, method, params, result)
+	})
+}
+
+// forward sends a call to the wrapped client.
+func (c *CachedClient) forward(ctx context.Context, method string, params, result interface{}) error {
+	if cc, ok := c.next.(ClientContext); ok {
+		return cc.CallContext(ctx, method, params, result)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.next.Call(method, params, result)
+}
+
+// stream forwards a streamed call, which is not cached. The clients of this
+// package stream the result on; any other client returns it whole.
+func (c *CachedClient) stream(ctx context.Context, method string, params interface{}, sd streamDecoder) error {
+	if d, ok := c.next.(interface {
+		do(ctx context.Context, method string, in, out interface{}) error
+	}); ok {
+		return d.do(ctx, method, params, sd)
+	}
+	var raw json.RawMessage
+	if err := c.forward(ctx, method, params, &raw); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if err := sd.decodeStream(dec, false); err != errNullValue {
+		return err
+	}
+	return nil
+}
+
+// NewBatch returns a Batch sent by the wrapped client, or one call at a
+// time when it is not a ClientContext. Its results are not cached, and a
+// batch holding a call that is not read-only (see ClassifyMethod) clears
+// the cache.
+func (c *CachedClient) NewBatch() *Batch {
+	return newRoutedBatch(c.sendBatch)
+}
+
+func (c *CachedClient) sendBatch(ctx context.Context, calls []*BatchCall) error {
+	for _, call := range calls {
+		if ClassifyMethod(call.Method) != MethodReadOnly {
+			defer c.changing()()
+			break
+		}
+	}
+	cc, ok := c.next.(ClientContext)
+	if !ok {
+		for _, call := range calls {
+			call.Error = c.forward(ctx, call.Method, call.Params, call.Result)
+		}
+		return nil
+	}
+	b := cc.NewBatch()
+	b.calls = calls
+	return b.SendContext(ctx)
+}
@@ -0,0 +1,231 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedClient(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	balance := uint64(100)
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[method]++
+			switch method {
+			case "getbalance":
+				writerpcResponseOK(H{"balance": balance, "unlocked_balance": balance}, w)
+			case "getheight":
+				writerpcResponseOK(H{"height": 42}, w)
+			case "transfer":
+				balance -= 10
+				writerpcResponseOK(H{"tx_hash": "abc"}, w)
+			default:
+				writerpcResponseError(ErrUnknown, "unknown", w)
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	cc := NewCachedClient(New(Config{
+		Address: sv0.URL + "/json_rpc",
+	}), CacheConfig{
+		TTLs: map[string]time.Duration{
+			"getbalance": time.Minute,
+			"getheight":  50 * time.Millisecond,
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		b, u, err := cc.GetBalance()
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(100), b)
+		assert.Equal(t, uint64(100), u)
+	}
+	assert.Equal(t, 1, calls["getbalance"])
+
+	// the raw result of Call is cached apart from the typed one
+	var res struct {
+		Balance uint64 `json:"balance"`
+	}
+	assert.NoError(t, cc.Call("getbalance", nil, &res))
+	assert.NoError(t, cc.Call("getbalance", nil, &res))
+	assert.Equal(t, uint64(100), res.Balance)
+	assert.Equal(t, 2, calls["getbalance"])
+
+	// a transfer clears the cache
+	_, err := cc.Transfer(TransferRequest{})
+	assert.NoError(t, err)
+	b, _, err := cc.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(90), b)
+	assert.Equal(t, 3, calls["getbalance"])
+
+	// expiry
+	_, err = cc.GetHeight()
+	assert.NoError(t, err)
+	_, err = cc.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls["getheight"])
+	time.Sleep(60 * time.Millisecond)
+	_, err = cc.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls["getheight"])
+
+	// errors and methods without a TTL are not cached
+	_, err = cc.GetLanguages()
+	assert.Error(t, err)
+	_, err = cc.GetLanguages()
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls["get_languages"])
+
+	st := cc.Stats()
+	assert.Equal(t, uint64(4), st.Hits)
+	assert.Equal(t, uint64(5), st.Misses)
+	// one per state-changing call
+	assert.Equal(t, uint64(1), st.Invalidations)
+	assert.Equal(t, 2, st.Entries)
+	assert.Equal(t, CacheMethodStats{Hits: 3, Misses: 3}, st.Methods["getbalance"])
+	assert.Equal(t, CacheMethodStats{Hits: 1, Misses: 2}, st.Methods["getheight"])
+}
+
+func TestCachedClientMaxEntries(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			writerpcResponseOK(H{"payments": []H{}}, w)
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	cc := NewCachedClient(New(Config{
+		Address: sv0.URL + "/json_rpc",
+	}), CacheConfig{
+		TTLs:       map[string]time.Duration{"get_payments": time.Minute},
+		MaxEntries: 2,
+	})
+	for _, id := range []string{"a", "b", "c", "d"} {
+		_, err := cc.GetPayments(id)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, cc.Stats().Entries)
+}
+
+func TestCachedClientContext(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			mu.Lock()
+			calls[method]++
+			mu.Unlock()
+			switch method {
+			case "getheight":
+				writerpcResponseOK(H{"height": 42}, w)
+			case "store":
+				writerpcResponseOK(H{}, w)
+			case "rescan_blockchain":
+				<-r.Context().Done()
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	cc := NewCachedClient(NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	}), CacheConfig{})
+
+	// the context reaches the wrapped client
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := cc.RescanBlockchainContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, uint64(1), cc.Stats().Invalidations)
+
+	height, err := cc.GetHeightContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), height)
+	_, err = cc.GetHeight()
+	assert.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, 1, calls["getheight"])
+	mu.Unlock()
+
+	// a batch with a state-changing call clears the cache
+	b := cc.NewBatch()
+	b.GetHeight(&height)
+	assert.NoError(t, b.Send())
+	assert.Equal(t, uint64(1), cc.Stats().Invalidations)
+	b = cc.NewBatch()
+	c0 := b.Add("store", nil, nil)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.Equal(t, uint64(2), cc.Stats().Invalidations)
+	_, err = cc.GetHeight()
+	assert.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, 3, calls["getheight"])
+	mu.Unlock()
+}
+
+// plainClient is a Client without the context-aware methods.
+type plainClient struct {
+	Client
+}
+
+func TestCachedClientContextless(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method == "get_transfers" {
+				writerpcResponseOK(H{"in": []H{{"txid": "a"}, {"txid": "b"}}}, w)
+				return true
+			}
+			writerpcResponseOK(H{"height": 42}, w)
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	cc := NewCachedClient(plainClient{New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})}, CacheConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cc.GetHeightContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	height, err := cc.GetHeightContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), height)
+
+	var h uint64
+	b := cc.NewBatch()
+	c0 := b.GetHeight(&h)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.Equal(t, uint64(42), h)
+
+	// streams are read whole from a client of another package
+	var txids []string
+	err = cc.GetTransfersStream(context.Background(), GetTransfersRequest{In: true}, func(tr Transfer) error {
+		txids = append(txids, tr.TxID+tr.Type)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ain", "bin"}, txids)
+}
@@ -0,0 +1,305 @@
+package walletrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// ErrCassetteMiss is returned by a replaying Cassette when no recorded
+// interaction matches a request.
+var ErrCassetteMiss = errors.New("walletrpc: no recorded interaction matches the request")
+
+// CassetteMode selects whether a Cassette records or replays.
+type CassetteMode int
+
+const (
+	// CassetteRecord forwards the requests to the server and records them.
+	CassetteRecord CassetteMode = iota
+	// CassetteReplay answers the requests from the recorded interactions,
+	// without any network.
+	CassetteReplay
+)
+
+// Interaction is a recorded JSON-RPC call.
+type Interaction struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *WalletError    `json:"error,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Cassette is an http.RoundTripper, for Config.Transport, that records
+// monero-wallet-rpc sessions to a file and replays them in tests.
+//
+// Requests are matched by method and params, ignoring the JSON-RPC id.
+// Identical requests are answered with their recorded responses in order.
+// A request that matches no unused interaction fails with ErrCassetteMiss,
+// and Unused reports the interactions that were not replayed.
+// Passwords, keys and the other secrets listed by the logging redaction are
+// replaced with Redacted in the params and results before they are
+// recorded, so replayed results hold Redacted too. Responses other than
+// 200 OK, such as digest authentication challenges, are not recorded.
+type Cassette struct {
+	mode CassetteMode
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []*Interaction
+	used         []bool
+}
+
+// NewCassette returns a Cassette backed by the file at path. In
+// CassetteReplay mode the file is loaded; in CassetteRecord mode requests
+// are sent through next (http.DefaultTransport when nil) and the file is
+// written by Save.
+func NewCassette(path string, mode CassetteMode, next http.RoundTripper) (*Cassette, error) {
+	c := &Cassette{
+		mode: mode,
+		path: path,
+		next: next,
+	}
+	if c.next == nil {
+		c.next = http.DefaultTransport
+	}
+	if mode == CassetteReplay {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var f cassetteFile
+		if err := json.Unmarshal(buf, &f); err != nil {
+			return nil, fmt.Errorf("walletrpc: invalid cassette %v: %v", path, err)
+		}
+		// the file is indented, params are matched in compact form
+		for _, it := range f.Interactions {
+			var b bytes.Buffer
+			if len(it.Params) > 0 && json.Compact(&b, it.Params) == nil {
+				it.Params = b.Bytes()
+			}
+		}
+		c.interactions = f.Interactions
+		c.used = make([]bool, len(f.Interactions))
+	}
+	return c, nil
+}
+
+// Interactions returns the recorded interactions.
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]Interaction, 0, len(c.interactions))
+	for _, it := range c.interactions {
+		list = append(list, *it)
+	}
+	return list
+}
+
+// Unused returns the recorded interactions that a replaying Cassette has
+// not answered yet, in recording order. A test can check that it is empty
+// once done, to catch calls it stopped making:
+//
+//	if unused := cas.Unused(); len(unused) > 0 {
+//		t.Errorf("%v interactions were not replayed", len(unused))
+//	}
+//
+// It is always empty in CassetteRecord mode.
+func (c *Cassette) Unused() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []Interaction
+	for i, it := range c.interactions {
+		if i < len(c.used) && !c.used[i] {
+			list = append(list, *it)
+		}
+	}
+	return list
+}
+
+// Save writes the recorded interactions to the cassette file.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	buf, err := json.MarshalIndent(&cassetteFile{c.interactions}, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(buf, '\n'), 0600)
+}
+
+// cassetteRequest is a JSON-RPC request read by a Cassette.
+type cassetteRequest struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	ID     json.RawMessage `json:"id"`
+}
+
+// RoundTrip records or replays a JSON-RPC request.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	reqs, batch, err := parseCassetteRequests(body)
+	if err != nil {
+		return nil, err
+	}
+	if c.mode == CassetteReplay {
+		return c.replay(req, reqs, batch)
+	}
+	return c.record(req, body, reqs, batch)
+}
+
+func parseCassetteRequests(body []byte) (reqs []cassetteRequest, batch bool, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		err = json.Unmarshal(body, &reqs)
+		return reqs, true, err
+	}
+	var r cassetteRequest
+	err = json.Unmarshal(body, &r)
+	return []cassetteRequest{r}, false, err
+}
+
+// canonical returns the redacted params or result of method as JSON with
+// sorted keys.
+func canonical(method string, v json.RawMessage, redact func(string, interface{}) interface{}) json.RawMessage {
+	if len(v) == 0 || string(v) == "null" {
+		return nil
+	}
+	buf, err := json.Marshal(redact(method, v))
+	if err != nil {
+		return nil
+	}
+	return buf
+}
+
+func (c *Cassette) replay(req *http.Request, reqs []cassetteRequest, batch bool) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resps := make([]*rpcResponse, 0, len(reqs))
+	for _, r := range reqs {
+		params := canonical(r.Method, r.Params, redactParams)
+		it := c.match(r.Method, params)
+		if it == nil {
+			return nil, fmt.Errorf("%w: %v %s", ErrCassetteMiss, r.Method, params)
+		}
+		resp := &rpcResponse{Version: "2.0", ID: r.ID}
+		if it.Error != nil {
+			buf, _ := json.Marshal(it.Error)
+			raw := json.RawMessage(buf)
+			resp.Error = &raw
+		} else {
+			raw := it.Result
+			if raw == nil {
+				raw = json.RawMessage("{}")
+			}
+			resp.Result = &raw
+		}
+		resps = append(resps, resp)
+	}
+	var out interface{} = resps
+	if !batch {
+		out = resps[0]
+	}
+	buf, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(buf)),
+		ContentLength: int64(len(buf)),
+		Request:       req,
+	}, nil
+}
+
+// match returns the first unused interaction for method and params.
+func (c *Cassette) match(method string, params json.RawMessage) *Interaction {
+	for i, it := range c.interactions {
+		if c.used[i] || it.Method != method || !bytes.Equal(it.Params, params) {
+			continue
+		}
+		c.used[i] = true
+		return it
+	}
+	return nil
+}
+
+func (c *Cassette) record(req *http.Request, body []byte, reqs []cassetteRequest, batch bool) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	out.ContentLength = int64(len(body))
+	resp, err := c.next.RoundTrip(out)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	rbody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rbody))
+
+	var resps []rpcResponse
+	if batch {
+		if json.Unmarshal(rbody, &resps) != nil {
+			return resp, nil
+		}
+	} else {
+		var r rpcResponse
+		if json.Unmarshal(rbody, &r) != nil {
+			return resp, nil
+		}
+		resps = []rpcResponse{r}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range reqs {
+		for _, rr := range resps {
+			if !bytes.Equal(rr.ID, r.ID) && batch {
+				continue
+			}
+			it := &Interaction{
+				Method: r.Method,
+				Params: canonical(r.Method, r.Params, redactParams),
+			}
+			if rr.Error != nil {
+				it.Error = &WalletError{}
+				if json.Unmarshal(*rr.Error, it.Error) != nil {
+					it.Error = nil
+					continue
+				}
+			} else if rr.Result != nil {
+				it.Result = canonical(r.Method, *rr.Result, redactResult)
+			}
+			c.interactions = append(c.interactions, it)
+			break
+		}
+	}
+	return resp, nil
+}
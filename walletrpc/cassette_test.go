@@ -0,0 +1,120 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	height := 0
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "open_wallet":
+				writerpcResponseOK(H{}, w)
+			case "query_key":
+				writerpcResponseOK(H{"key": "secret seed words"}, w)
+			case "getbalance":
+				writerpcResponseOK(H{"balance": uint64(18446744073709551615), "unlocked_balance": 1}, w)
+			case "getheight":
+				height++
+				writerpcResponseOK(H{"height": height}, w)
+			case "get_transfer_by_txid":
+				writerpcResponseError(ErrWrongTxID, "Transaction not found.", w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+
+	//
+	// record
+	cas, err := NewCassette(path, CassetteRecord, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rpccl := NewClientContext(Config{
+		Address:   sv0.URL + "/json_rpc",
+		Transport: cas,
+	})
+	assert.NoError(t, rpccl.OpenWallet("wallet", "hunter2"))
+	key, err := rpccl.QueryKey(QueryKeyMnemonic)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret seed words", key)
+	_, err = rpccl.GetTransferByTxID("abc")
+	assert.True(t, errors.Is(err, ErrWrongTxID))
+	for i := 0; i < 2; i++ {
+		_, err = rpccl.GetHeight()
+		assert.NoError(t, err)
+	}
+	var balance, unlocked uint64
+	b := rpccl.NewBatch()
+	b.GetBalance(&balance, &unlocked)
+	assert.NoError(t, b.Send())
+	sv0.Close()
+
+	assert.Len(t, cas.Interactions(), 6)
+	assert.Empty(t, cas.Unused())
+	assert.NoError(t, cas.Save())
+	buf, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(buf), "hunter2")
+	assert.NotContains(t, string(buf), "secret seed words")
+	assert.Contains(t, string(buf), "18446744073709551615")
+
+	//
+	// replay, with no server
+	cas, err = NewCassette(path, CassetteReplay, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	rpccl = NewClientContext(Config{
+		Address:   sv0.URL + "/json_rpc",
+		Transport: cas,
+	})
+	// the password is not recorded, so any password matches
+	assert.NoError(t, rpccl.OpenWallet("wallet", "other"))
+	key, err = rpccl.QueryKey(QueryKeyMnemonic)
+	assert.NoError(t, err)
+	assert.Equal(t, Redacted, key)
+	_, err = rpccl.GetTransferByTxID("abc")
+	assert.True(t, errors.Is(err, ErrWrongTxID))
+	for i := uint64(1); i <= 2; i++ {
+		h, err := rpccl.GetHeight()
+		assert.NoError(t, err)
+		assert.Equal(t, i, h)
+	}
+	unused := cas.Unused()
+	if assert.Len(t, unused, 1) {
+		assert.Equal(t, "getbalance", unused[0].Method)
+	}
+	balance, unlocked, err = rpccl.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(18446744073709551615), balance)
+	assert.Equal(t, uint64(1), unlocked)
+
+	// every interaction was used
+	assert.Empty(t, cas.Unused())
+	_, err = rpccl.GetHeight()
+	assert.True(t, errors.Is(err, ErrCassetteMiss))
+	_, err = rpccl.GetTransferByTxID("def")
+	assert.True(t, errors.Is(err, ErrCassetteMiss))
+
+	_, err = NewCassette(filepath.Join(dir, "missing.json"), CassetteReplay, nil)
+	assert.Error(t, err)
+}
@@ -2,13 +2,21 @@ package walletrpc
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
-
-	"github.com/gorilla/rpc/v2/json2"
+	"strings"
 )
 
 // Client is a monero-wallet-rpc client.
+//
+// Methods are added to Client and ClientContext as monero-wallet-rpc
+// calls are covered, which breaks types implementing them outside this
+// package; see CHANGELOG.md. Such types can embed a Client to pick up new
+// methods.
 type Client interface {
 	// Return the wallet's balance.
 	GetBalance() (balance, unlockedBalance uint64, err error)
@@ -104,19 +112,156 @@ type Client interface {
 	// Open a wallet. You need to have set the argument "–wallet-dir" when
 	// launching monero-wallet-rpc to make this work.
 	OpenWallet(filename, password string) error
+	// Create a new account, with an optional label.
+	CreateAccount(label string) (index uint64, address string, err error)
+	// Get all accounts, with their balances. When tag is not empty, only the
+	// accounts with that tag are returned.
+	GetAccounts(tag string) (resp *GetAccountsResponse, err error)
+	// Set the label of an account.
+	LabelAccount(index uint64, label string) error
+	// Get the account tags, with their descriptions and accounts.
+	GetAccountTags() (tags []AccountTag, err error)
+	// Apply a tag to a set of accounts.
+	TagAccounts(tag string, accounts []uint64) error
+	// Remove the tag of a set of accounts.
+	UntagAccounts(accounts []uint64) error
+	// Set the description of an account tag.
+	SetAccountTagDescription(tag, description string) error
+	// Get the base address of an account and its subaddresses. When
+	// addressIndexes is not empty, only these subaddresses are returned.
+	GetAddresses(accountIndex uint64, addressIndexes []uint64) (resp *GetAddressResponse, err error)
+	// Create count subaddresses (1 when zero) in an account, with an
+	// optional label.
+	CreateAddress(accountIndex uint64, label string, count uint64) (resp *CreateAddressResponse, err error)
+	// Set the label of a subaddress.
+	LabelAddress(index SubaddressIndex, label string) error
+	// Get the account (major) and subaddress (minor) index of an address of
+	// the wallet.
+	GetAddressIndex(address string) (index SubaddressIndex, err error)
+	// Call calls any monero-wallet-rpc method, including the ones this
+	// package does not wrap yet. params is sent as the JSON-RPC params and
+	// the result is decoded into result (a pointer, or nil to discard it).
+	Call(method string, params, result interface{}) error
+	// CallRaw is like Call, but returns the undecoded JSON result.
+	CallRaw(method string, params interface{}) (result json.RawMessage, err error)
+}
+
+// ClientContext is a Client that also exposes a context-aware variant of
+// every method. Cancelling the context or reaching its deadline aborts the
+// underlying HTTP request.
+type ClientContext interface {
+	Client
+	GetBalanceContext(ctx context.Context) (balance, unlockedBalance uint64, err error)
+	GetAddressContext(ctx context.Context) (address string, err error)
+	GetHeightContext(ctx context.Context) (height uint64, err error)
+	TransferContext(ctx context.Context, req TransferRequest) (resp *TransferResponse, err error)
+	TransferSplitContext(ctx context.Context, req TransferRequest) (resp *TransferSplitResponse, err error)
+	SweepDustContext(ctx context.Context) (txHashList []string, err error)
+	SweepAllContext(ctx context.Context, req SweepAllRequest) (resp *SweepAllResponse, err error)
+	StoreContext(ctx context.Context) error
+	GetPaymentsContext(ctx context.Context, paymentid string) (payments []Payment, err error)
+	GetBulkPaymentsContext(ctx context.Context, paymentids []string, minblockheight uint) (payments []Payment, err error)
+	GetTransfersContext(ctx context.Context, req GetTransfersRequest) (resp *GetTransfersResponse, err error)
+	GetTransferByTxIDContext(ctx context.Context, txid string) (transfer *Transfer, err error)
+	IncomingTransfersContext(ctx context.Context, transfertype GetTransferType) (transfers []IncTransfer, err error)
+	QueryKeyContext(ctx context.Context, keytype QueryKeyType) (key string, err error)
+	MakeIntegratedAddressContext(ctx context.Context, paymentid string) (integratedaddr string, err error)
+	SplitIntegratedAddressContext(ctx context.Context, integratedaddr string) (paymentid, address string, err error)
+	StopWalletContext(ctx context.Context) error
+	MakeURIContext(ctx context.Context, req URIDef) (uri string, err error)
+	ParseURIContext(ctx context.Context, uri string) (parsed *URIDef, err error)
+	RescanBlockchainContext(ctx context.Context) error
+	SetTxNotesContext(ctx context.Context, txids, notes []string) error
+	GetTxNotesContext(ctx context.Context, txids []string) (notes []string, err error)
+	SignContext(ctx context.Context, data string) (signature string, err error)
+	VerifyContext(ctx context.Context, data, address, signature string) (good bool, err error)
+	ExportKeyImagesContext(ctx context.Context) (signedkeyimages []SignedKeyImage, err error)
+	ImportKeyImagesContext(ctx context.Context, signedkeyimages []SignedKeyImage) (resp *ImportKeyImageResponse, err error)
+	GetAddressBookContext(ctx context.Context, indexes []uint64) (entries []AddressBookEntry, err error)
+	AddAddressBookContext(ctx context.Context, entry AddressBookEntry) (index uint64, err error)
+	DeleteAddressBookContext(ctx context.Context, index uint64) error
+	RescanSpentContext(ctx context.Context) error
+	StartMiningContext(ctx context.Context, threads uint, background, ignorebattery bool) error
+	StopMiningContext(ctx context.Context) error
+	GetLanguagesContext(ctx context.Context) (languages []string, err error)
+	CreateWalletContext(ctx context.Context, filename, password, language string) error
+	OpenWalletContext(ctx context.Context, filename, password string) error
+	CreateAccountContext(ctx context.Context, label string) (index uint64, address string, err error)
+	GetAccountsContext(ctx context.Context, tag string) (resp *GetAccountsResponse, err error)
+	LabelAccountContext(ctx context.Context, index uint64, label string) error
+	GetAccountTagsContext(ctx context.Context) (tags []AccountTag, err error)
+	TagAccountsContext(ctx context.Context, tag string, accounts []uint64) error
+	UntagAccountsContext(ctx context.Context, accounts []uint64) error
+	SetAccountTagDescriptionContext(ctx context.Context, tag, description string) error
+	GetAddressesContext(ctx context.Context, accountIndex uint64, addressIndexes []uint64) (resp *GetAddressResponse, err error)
+	CreateAddressContext(ctx context.Context, accountIndex uint64, label string, count uint64) (resp *CreateAddressResponse, err error)
+	LabelAddressContext(ctx context.Context, index SubaddressIndex, label string) error
+	GetAddressIndexContext(ctx context.Context, address string) (index SubaddressIndex, err error)
+	CallContext(ctx context.Context, method string, params, result interface{}) error
+	CallRawContext(ctx context.Context, method string, params interface{}) (result json.RawMessage, err error)
+	// GetTransfersStream is like GetTransfers, but hands every transfer to
+	// fn as it is decoded instead of returning them all at once. The
+	// transfer Type is set to the name of the list it came from ("in",
+	// "out", "pool"...) when the wallet does not report it. Returning an
+	// error from fn aborts the call.
+	//
+	// fn is only called once the response id and version are checked and
+	// no error is reported, but a response that is cut short, too large or
+	// invalid after the result still fails the call after fn saw some of
+	// the transfers.
+	GetTransfersStream(ctx context.Context, req GetTransfersRequest, fn func(t Transfer) error) error
+	// IncomingTransfersStream is like IncomingTransfers, but hands every
+	// transfer to fn as it is decoded, with the same guarantees as
+	// GetTransfersStream.
+	IncomingTransfersStream(ctx context.Context, transfertype GetTransferType, fn func(t IncTransfer) error) error
+	// NewBatch returns a Batch to send several calls in a single request.
+	NewBatch() *Batch
 }
 
 // New returns a new monero-wallet-rpc client.
 func New(cfg Config) Client {
+	return newClient(cfg)
+}
+
+// NewClientContext returns a new monero-wallet-rpc client that accepts a
+// context.Context on every call.
+func NewClientContext(cfg Config) ClientContext {
+	return newClient(cfg)
+}
+
+func newClient(cfg Config) *client {
 	cl := &client{
 		addr:    cfg.Address,
 		headers: cfg.CustomHeaders,
+		retry:   cfg.Retry,
+		queue:   cfg.Queue,
+		strict:  cfg.StrictDecoding,
 	}
-	if cfg.Transport == nil {
+	switch {
+	case cfg.MaxResponseSize == 0:
+		cl.maxResponseSize = DefaultMaxResponseSize
+	case cfg.MaxResponseSize < 0:
+		cl.maxResponseSize = -1
+	default:
+		cl.maxResponseSize = cfg.MaxResponseSize
+	}
+	interceptors := cfg.Interceptors
+	if cfg.Logger != nil {
+		interceptors = append([]Interceptor{newLoggingInterceptor(cfg.Logger)}, interceptors...)
+	}
+	cl.interceptors = interceptors
+	cl.invoke = chainInvoker(interceptors, cl.call)
+	transport, err := buildTransport(cfg)
+	if err != nil {
+		// reported by every call
+		cl.initErr = err
+	}
+	if transport == nil && cfg.Timeout == 0 {
 		cl.httpcl = http.DefaultClient
 	} else {
 		cl.httpcl = &http.Client{
-			Transport: cfg.Transport,
+			Transport: transport,
+			Timeout:   cfg.Timeout,
 		}
 	}
 	return cl
@@ -126,17 +271,69 @@ type client struct {
 	httpcl  *http.Client
 	addr    string
 	headers map[string]string
+	retry   *RetryPolicy
+	invoke  Invoker
+	queue   *Queue
+	strict  bool
+	// set when the Config is invalid
+	initErr error
+	// -1 when unlimited
+	maxResponseSize int64
+	// set to 1 once the server rejected a batch request
+	nobatch int32
+	// the interceptors of invoke, for the calls of a Batch
+	interceptors []Interceptor
 }
 
-func (c *client) do(method string, in, out interface{}) error {
-	payload, err := json2.EncodeClientRequest(method, in)
+func (c *client) do(ctx context.Context, method string, in, out interface{}) error {
+	return c.invoke(ctx, method, in, out)
+}
+
+// call performs a call, retrying it according to the retry policy.
+func (c *client) call(ctx context.Context, method string, in, out interface{}) error {
+	if c.initErr != nil {
+		return c.initErr
+	}
+	attempts := c.retry.attempts()
+	var err error
+	for i := 1; ; i++ {
+		var state attemptState
+		state, err = c.doQueued(ctx, method, in, out)
+		if err == nil || i >= attempts || ctx.Err() != nil {
+			return err
+		}
+		if !c.retry.shouldRetry(method, state, err) {
+			return err
+		}
+		if serr := sleepContext(ctx, c.retry.backoff(i)); serr != nil {
+			return err
+		}
+	}
+}
+
+// doQueued waits for its turn in the queue, if any, and sends the request.
+func (c *client) doQueued(ctx context.Context, method string, in, out interface{}) (attemptState, error) {
+	if c.queue == nil {
+		return c.doOnce(ctx, method, in, out)
+	}
+	if err := c.queue.acquire(ctx, c.queue.priority(ctx, method)); err != nil {
+		return attemptNotSent, err
+	}
+	defer c.queue.release()
+	return c.doOnce(ctx, method, in, out)
+}
+
+func (c *client) doOnce(ctx context.Context, method string, in, out interface{}) (attemptState, error) {
+	rpcreq := newRPCRequest(method, in)
+	payload, err := json.Marshal(rpcreq)
 	if err != nil {
-		return err
+		return attemptNotSent, err
 	}
 	req, err := http.NewRequest(http.MethodPost, c.addr, bytes.NewBuffer(payload))
 	if err != nil {
-		return err
+		return attemptNotSent, err
 	}
+	req = req.WithContext(ctx)
 	if c.headers != nil {
 		for k, v := range c.headers {
 			req.Header.Set(k, v)
@@ -144,37 +341,75 @@ func (c *client) do(method string, in, out interface{}) error {
 	}
 	resp, err := c.httpcl.Do(req)
 	if err != nil {
-		return err
+		if isDialError(err) {
+			return attemptNotSent, err
+		}
+		// the request may have reached the wallet
+		return attemptSent, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("http status %v", resp.StatusCode)
+		return attemptResponded, newHTTPError(resp)
 	}
-	defer resp.Body.Close()
+	// drain whatever is left so the connection can be reused
+	defer drainBody(resp.Body)
 
-	// in theory this is only done to catch
-	// any monero related errors if
-	// we are not expecting any data back
-	if out == nil {
-		v := &json2.EmptyResponse{}
-		return json2.DecodeClientResponse(resp.Body, v)
+	if sd, ok := out.(streamDecoder); ok {
+		return attemptResponded, decodeStreamResponse(resp.Body, rpcreq.ID, method, sd, c.strict, c.maxResponseSize)
+	}
+
+	body, err := readBody(resp.Body, c.maxResponseSize)
+	if err != nil {
+		if err == ErrResponseTooLarge {
+			return attemptResponded, err
+		}
+		return attemptSent, err
+	}
+	var rpcresp rpcResponse
+	if err := json.Unmarshal(body, &rpcresp); err != nil {
+		return attemptResponded, err
+	}
+	if err := rpcresp.validate(rpcreq.ID); err != nil {
+		return attemptResponded, err
+	}
+	return attemptResponded, rpcresp.decodeResult(method, out, c.strict)
+}
+
+// maxHTTPErrorBody is the size of the body excerpt kept in a HTTPError.
+const maxHTTPErrorBody = 512
+
+// newHTTPError reads an excerpt of the body of a failed response and
+// closes it.
+func newHTTPError(resp *http.Response) *HTTPError {
+	excerpt, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxHTTPErrorBody))
+	drainBody(resp.Body)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(excerpt)),
 	}
-	return json2.DecodeClientResponse(resp.Body, out)
 }
 
 func (c *client) GetBalance() (balance, unlockedBalance uint64, err error) {
+	return c.GetBalanceContext(context.Background())
+}
+
+func (c *client) GetBalanceContext(ctx context.Context) (balance, unlockedBalance uint64, err error) {
 	jd := struct {
 		Balance         uint64 `json:"balance"`
 		UnlockedBalance uint64 `json:"unlocked_balance"`
 	}{}
-	err = c.do("getbalance", nil, &jd)
+	err = c.do(ctx, "getbalance", nil, &jd)
 	return jd.Balance, jd.UnlockedBalance, err
 }
 
 func (c *client) GetAddress() (address string, err error) {
+	return c.GetAddressContext(context.Background())
+}
+
+func (c *client) GetAddressContext(ctx context.Context) (address string, err error) {
 	jd := struct {
 		Address string `json:"address"`
 	}{}
-	err = c.do("getaddress", nil, &jd)
+	err = c.do(ctx, "getaddress", nil, &jd)
 	if err != nil {
 		return "", err
 	}
@@ -182,10 +417,14 @@ func (c *client) GetAddress() (address string, err error) {
 }
 
 func (c *client) GetHeight() (height uint64, err error) {
+	return c.GetHeightContext(context.Background())
+}
+
+func (c *client) GetHeightContext(ctx context.Context) (height uint64, err error) {
 	jd := struct {
 		Height uint64 `json:"height"`
 	}{}
-	err = c.do("getheight", nil, &jd)
+	err = c.do(ctx, "getheight", nil, &jd)
 	if err != nil {
 		return 0, err
 	}
@@ -193,8 +432,12 @@ func (c *client) GetHeight() (height uint64, err error) {
 }
 
 func (c *client) Transfer(req TransferRequest) (resp *TransferResponse, err error) {
+	return c.TransferContext(context.Background(), req)
+}
+
+func (c *client) TransferContext(ctx context.Context, req TransferRequest) (resp *TransferResponse, err error) {
 	resp = &TransferResponse{}
-	err = c.do("transfer", &req, resp)
+	err = c.do(ctx, "transfer", &req, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -202,8 +445,12 @@ func (c *client) Transfer(req TransferRequest) (resp *TransferResponse, err erro
 }
 
 func (c *client) TransferSplit(req TransferRequest) (resp *TransferSplitResponse, err error) {
+	return c.TransferSplitContext(context.Background(), req)
+}
+
+func (c *client) TransferSplitContext(ctx context.Context, req TransferRequest) (resp *TransferSplitResponse, err error) {
 	resp = &TransferSplitResponse{}
-	err = c.do("transfer_split", &req, resp)
+	err = c.do(ctx, "transfer_split", &req, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -211,10 +458,14 @@ func (c *client) TransferSplit(req TransferRequest) (resp *TransferSplitResponse
 }
 
 func (c *client) SweepDust() (txHashList []string, err error) {
+	return c.SweepDustContext(context.Background())
+}
+
+func (c *client) SweepDustContext(ctx context.Context) (txHashList []string, err error) {
 	jd := struct {
 		TxHashList []string `json:"tx_hash_list"`
 	}{}
-	err = c.do("sweep_dust", nil, &jd)
+	err = c.do(ctx, "sweep_dust", nil, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -222,8 +473,12 @@ func (c *client) SweepDust() (txHashList []string, err error) {
 }
 
 func (c *client) SweepAll(req SweepAllRequest) (resp *SweepAllResponse, err error) {
+	return c.SweepAllContext(context.Background(), req)
+}
+
+func (c *client) SweepAllContext(ctx context.Context, req SweepAllRequest) (resp *SweepAllResponse, err error) {
 	resp = &SweepAllResponse{}
-	err = c.do("sweep_all", &req, resp)
+	err = c.do(ctx, "sweep_all", &req, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -231,10 +486,18 @@ func (c *client) SweepAll(req SweepAllRequest) (resp *SweepAllResponse, err erro
 }
 
 func (c *client) Store() error {
-	return c.do("store", nil, nil)
+	return c.StoreContext(context.Background())
+}
+
+func (c *client) StoreContext(ctx context.Context) error {
+	return c.do(ctx, "store", nil, nil)
 }
 
 func (c *client) GetPayments(paymentid string) (payments []Payment, err error) {
+	return c.GetPaymentsContext(context.Background(), paymentid)
+}
+
+func (c *client) GetPaymentsContext(ctx context.Context, paymentid string) (payments []Payment, err error) {
 	jin := struct {
 		PaymentID string `json:"payment_id"`
 	}{
@@ -243,7 +506,7 @@ func (c *client) GetPayments(paymentid string) (payments []Payment, err error) {
 	jd := struct {
 		Payments []Payment `json:"payments"`
 	}{}
-	err = c.do("get_payments", &jin, &jd)
+	err = c.do(ctx, "get_payments", &jin, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +514,10 @@ func (c *client) GetPayments(paymentid string) (payments []Payment, err error) {
 }
 
 func (c *client) GetBulkPayments(paymentids []string, minblockheight uint) (payments []Payment, err error) {
+	return c.GetBulkPaymentsContext(context.Background(), paymentids, minblockheight)
+}
+
+func (c *client) GetBulkPaymentsContext(ctx context.Context, paymentids []string, minblockheight uint) (payments []Payment, err error) {
 	jin := struct {
 		PaymentIDs     []string `json:"payment_ids"`
 		MinBlockHeight uint     `json:"min_block_height"`
@@ -261,7 +528,7 @@ func (c *client) GetBulkPayments(paymentids []string, minblockheight uint) (paym
 	jd := struct {
 		Payments []Payment `json:"payments"`
 	}{}
-	err = c.do("get_bulk_payments", &jin, &jd)
+	err = c.do(ctx, "get_bulk_payments", &jin, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -269,12 +536,20 @@ func (c *client) GetBulkPayments(paymentids []string, minblockheight uint) (paym
 }
 
 func (c *client) GetTransfers(req GetTransfersRequest) (resp *GetTransfersResponse, err error) {
+	return c.GetTransfersContext(context.Background(), req)
+}
+
+func (c *client) GetTransfersContext(ctx context.Context, req GetTransfersRequest) (resp *GetTransfersResponse, err error) {
 	resp = &GetTransfersResponse{}
-	err = c.do("get_transfers", &req, resp)
+	err = c.do(ctx, "get_transfers", &req, resp)
 	return
 }
 
 func (c *client) GetTransferByTxID(txid string) (transfer *Transfer, err error) {
+	return c.GetTransferByTxIDContext(context.Background(), txid)
+}
+
+func (c *client) GetTransferByTxIDContext(ctx context.Context, txid string) (transfer *Transfer, err error) {
 	jin := struct {
 		TxID string `json:"txid"`
 	}{
@@ -283,7 +558,7 @@ func (c *client) GetTransferByTxID(txid string) (transfer *Transfer, err error)
 	jd := struct {
 		Transfer *Transfer `json:"transfer"`
 	}{}
-	err = c.do("get_transfer_by_txid", &jin, &jd)
+	err = c.do(ctx, "get_transfer_by_txid", &jin, &jd)
 	if err != nil {
 		return
 	}
@@ -292,6 +567,10 @@ func (c *client) GetTransferByTxID(txid string) (transfer *Transfer, err error)
 }
 
 func (c *client) IncomingTransfers(transfertype GetTransferType) (transfers []IncTransfer, err error) {
+	return c.IncomingTransfersContext(context.Background(), transfertype)
+}
+
+func (c *client) IncomingTransfersContext(ctx context.Context, transfertype GetTransferType) (transfers []IncTransfer, err error) {
 	jin := struct {
 		TransferType GetTransferType `json:"transfer_type"`
 	}{
@@ -300,7 +579,7 @@ func (c *client) IncomingTransfers(transfertype GetTransferType) (transfers []In
 	jd := struct {
 		Transfers []IncTransfer `json:"transfers"`
 	}{}
-	err = c.do("incoming_transfers", &jin, &jd)
+	err = c.do(ctx, "incoming_transfers", &jin, &jd)
 	if err != nil {
 		return
 	}
@@ -309,6 +588,10 @@ func (c *client) IncomingTransfers(transfertype GetTransferType) (transfers []In
 }
 
 func (c *client) QueryKey(keytype QueryKeyType) (key string, err error) {
+	return c.QueryKeyContext(context.Background(), keytype)
+}
+
+func (c *client) QueryKeyContext(ctx context.Context, keytype QueryKeyType) (key string, err error) {
 	jin := struct {
 		KeyType QueryKeyType `json:"key_type"`
 	}{
@@ -317,7 +600,7 @@ func (c *client) QueryKey(keytype QueryKeyType) (key string, err error) {
 	jd := struct {
 		Key string `json:"key"`
 	}{}
-	err = c.do("query_key", &jin, &jd)
+	err = c.do(ctx, "query_key", &jin, &jd)
 	if err != nil {
 		return
 	}
@@ -326,6 +609,10 @@ func (c *client) QueryKey(keytype QueryKeyType) (key string, err error) {
 }
 
 func (c *client) MakeIntegratedAddress(paymentid string) (integratedaddr string, err error) {
+	return c.MakeIntegratedAddressContext(context.Background(), paymentid)
+}
+
+func (c *client) MakeIntegratedAddressContext(ctx context.Context, paymentid string) (integratedaddr string, err error) {
 	jin := struct {
 		PaymentID string `json:"payment_id"`
 	}{
@@ -334,7 +621,7 @@ func (c *client) MakeIntegratedAddress(paymentid string) (integratedaddr string,
 	jd := struct {
 		Address string `json:"integrated_address"`
 	}{}
-	err = c.do("make_integrated_address", &jin, &jd)
+	err = c.do(ctx, "make_integrated_address", &jin, &jd)
 	if err != nil {
 		return
 	}
@@ -343,6 +630,10 @@ func (c *client) MakeIntegratedAddress(paymentid string) (integratedaddr string,
 }
 
 func (c *client) SplitIntegratedAddress(integratedaddr string) (paymentid, address string, err error) {
+	return c.SplitIntegratedAddressContext(context.Background(), integratedaddr)
+}
+
+func (c *client) SplitIntegratedAddressContext(ctx context.Context, integratedaddr string) (paymentid, address string, err error) {
 	jin := struct {
 		IntegratedAddress string `json:"integrated_address"`
 	}{
@@ -352,7 +643,7 @@ func (c *client) SplitIntegratedAddress(integratedaddr string) (paymentid, addre
 		Address   string `json:"standard_address"`
 		PaymentID string `json:"payment_id"`
 	}{}
-	err = c.do("split_integrated_address", &jin, &jd)
+	err = c.do(ctx, "split_integrated_address", &jin, &jd)
 	if err != nil {
 		return
 	}
@@ -362,14 +653,22 @@ func (c *client) SplitIntegratedAddress(integratedaddr string) (paymentid, addre
 }
 
 func (c *client) StopWallet() error {
-	return c.do("stop_wallet", nil, nil)
+	return c.StopWalletContext(context.Background())
+}
+
+func (c *client) StopWalletContext(ctx context.Context) error {
+	return c.do(ctx, "stop_wallet", nil, nil)
 }
 
 func (c *client) MakeURI(req URIDef) (uri string, err error) {
+	return c.MakeURIContext(context.Background(), req)
+}
+
+func (c *client) MakeURIContext(ctx context.Context, req URIDef) (uri string, err error) {
 	jd := struct {
 		URI string `json:"uri"`
 	}{}
-	err = c.do("make_uri", &req, &jd)
+	err = c.do(ctx, "make_uri", &req, &jd)
 	if err != nil {
 		return
 	}
@@ -378,24 +677,42 @@ func (c *client) MakeURI(req URIDef) (uri string, err error) {
 }
 
 func (c *client) ParseURI(uri string) (parsed *URIDef, err error) {
+	return c.ParseURIContext(context.Background(), uri)
+}
+
+func (c *client) ParseURIContext(ctx context.Context, uri string) (parsed *URIDef, err error) {
 	jin := struct {
 		URI string `json:"uri"`
 	}{
 		uri,
 	}
-	parsed = &URIDef{}
-	err = c.do("parse_uri", &jin, parsed)
+	jd := struct {
+		URI *URIDef `json:"uri"`
+	}{}
+	err = c.do(ctx, "parse_uri", &jin, &jd)
 	if err != nil {
 		return nil, err
 	}
+	if jd.URI == nil {
+		return nil, fmt.Errorf("%w: parse_uri result has no uri", ErrInvalidResponse)
+	}
+	parsed = jd.URI
 	return
 }
 
 func (c *client) RescanBlockchain() error {
-	return c.do("rescan_blockchain", nil, nil)
+	return c.RescanBlockchainContext(context.Background())
+}
+
+func (c *client) RescanBlockchainContext(ctx context.Context) error {
+	return c.do(ctx, "rescan_blockchain", nil, nil)
 }
 
 func (c *client) SetTxNotes(txids, notes []string) error {
+	return c.SetTxNotesContext(context.Background(), txids, notes)
+}
+
+func (c *client) SetTxNotesContext(ctx context.Context, txids, notes []string) error {
 	jin := struct {
 		TxIDs []string `json:"txids"`
 		Notes []string `json:"notes"`
@@ -403,10 +720,14 @@ func (c *client) SetTxNotes(txids, notes []string) error {
 		txids,
 		notes,
 	}
-	return c.do("set_tx_notes", &jin, nil)
+	return c.do(ctx, "set_tx_notes", &jin, nil)
 }
 
 func (c *client) GetTxNotes(txids []string) (notes []string, err error) {
+	return c.GetTxNotesContext(context.Background(), txids)
+}
+
+func (c *client) GetTxNotesContext(ctx context.Context, txids []string) (notes []string, err error) {
 	jin := struct {
 		TxIDs []string `json:"txids"`
 	}{
@@ -415,7 +736,7 @@ func (c *client) GetTxNotes(txids []string) (notes []string, err error) {
 	jd := struct {
 		Notes []string `json:"notes"`
 	}{}
-	err = c.do("get_tx_notes", &jin, &jd)
+	err = c.do(ctx, "get_tx_notes", &jin, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -424,6 +745,10 @@ func (c *client) GetTxNotes(txids []string) (notes []string, err error) {
 }
 
 func (c *client) Sign(data string) (signature string, err error) {
+	return c.SignContext(context.Background(), data)
+}
+
+func (c *client) SignContext(ctx context.Context, data string) (signature string, err error) {
 	jin := struct {
 		Data string `json:"data"`
 	}{
@@ -432,7 +757,7 @@ func (c *client) Sign(data string) (signature string, err error) {
 	jd := struct {
 		Signature string `json:"signature"`
 	}{}
-	err = c.do("sign", &jin, &jd)
+	err = c.do(ctx, "sign", &jin, &jd)
 	if err != nil {
 		return "", err
 	}
@@ -441,6 +766,10 @@ func (c *client) Sign(data string) (signature string, err error) {
 }
 
 func (c *client) Verify(data, address, signature string) (good bool, err error) {
+	return c.VerifyContext(context.Background(), data, address, signature)
+}
+
+func (c *client) VerifyContext(ctx context.Context, data, address, signature string) (good bool, err error) {
 	jin := struct {
 		Data      string `json:"data"`
 		Address   string `json:"address"`
@@ -453,7 +782,7 @@ func (c *client) Verify(data, address, signature string) (good bool, err error)
 	jd := struct {
 		Good bool `json:"good"`
 	}{}
-	err = c.do("verify", &jin, &jd)
+	err = c.do(ctx, "verify", &jin, &jd)
 	if err != nil {
 		return false, err
 	}
@@ -462,22 +791,30 @@ func (c *client) Verify(data, address, signature string) (good bool, err error)
 }
 
 func (c *client) ExportKeyImages() (signedkeyimages []SignedKeyImage, err error) {
+	return c.ExportKeyImagesContext(context.Background())
+}
+
+func (c *client) ExportKeyImagesContext(ctx context.Context) (signedkeyimages []SignedKeyImage, err error) {
 	jd := struct {
 		SignedKeyImages []SignedKeyImage `json:"signed_key_images"`
 	}{}
-	err = c.do("export_key_images", nil, &jd)
+	err = c.do(ctx, "export_key_images", nil, &jd)
 	signedkeyimages = jd.SignedKeyImages
 	return
 }
 
 func (c *client) ImportKeyImages(signedkeyimages []SignedKeyImage) (resp *ImportKeyImageResponse, err error) {
+	return c.ImportKeyImagesContext(context.Background(), signedkeyimages)
+}
+
+func (c *client) ImportKeyImagesContext(ctx context.Context, signedkeyimages []SignedKeyImage) (resp *ImportKeyImageResponse, err error) {
 	jin := struct {
 		SignedKeyImages []SignedKeyImage `json:"signed_key_images"`
 	}{
 		signedkeyimages,
 	}
 	resp = &ImportKeyImageResponse{}
-	err = c.do("import_key_images", &jin, resp)
+	err = c.do(ctx, "import_key_images", &jin, resp)
 	if err != nil {
 		return nil, err
 	}
@@ -485,6 +822,10 @@ func (c *client) ImportKeyImages(signedkeyimages []SignedKeyImage) (resp *Import
 }
 
 func (c *client) GetAddressBook(indexes []uint64) (entries []AddressBookEntry, err error) {
+	return c.GetAddressBookContext(context.Background(), indexes)
+}
+
+func (c *client) GetAddressBookContext(ctx context.Context, indexes []uint64) (entries []AddressBookEntry, err error) {
 	jin := struct {
 		Indexes []uint64 `json:"entries"`
 	}{
@@ -493,7 +834,7 @@ func (c *client) GetAddressBook(indexes []uint64) (entries []AddressBookEntry, e
 	jd := struct {
 		Entries []AddressBookEntry `json:"entries"`
 	}{}
-	err = c.do("get_address_book", &jin, &jd)
+	err = c.do(ctx, "get_address_book", &jin, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -502,11 +843,15 @@ func (c *client) GetAddressBook(indexes []uint64) (entries []AddressBookEntry, e
 }
 
 func (c *client) AddAddressBook(entry AddressBookEntry) (index uint64, err error) {
+	return c.AddAddressBookContext(context.Background(), entry)
+}
+
+func (c *client) AddAddressBookContext(ctx context.Context, entry AddressBookEntry) (index uint64, err error) {
 	entry.Index = 0
 	jd := struct {
 		Index uint64 `json:"index"`
 	}{}
-	err = c.do("add_address_book", &entry, &jd)
+	err = c.do(ctx, "add_address_book", &entry, &jd)
 	if err != nil {
 		return 0, err
 	}
@@ -515,19 +860,31 @@ func (c *client) AddAddressBook(entry AddressBookEntry) (index uint64, err error
 }
 
 func (c *client) DeleteAddressBook(index uint64) error {
+	return c.DeleteAddressBookContext(context.Background(), index)
+}
+
+func (c *client) DeleteAddressBookContext(ctx context.Context, index uint64) error {
 	jin := struct {
 		Index uint64 `json:"index"`
 	}{
 		index,
 	}
-	return c.do("delete_address_book", &jin, nil)
+	return c.do(ctx, "delete_address_book", &jin, nil)
 }
 
 func (c *client) RescanSpent() error {
-	return c.do("rescan_spent", nil, nil)
+	return c.RescanSpentContext(context.Background())
+}
+
+func (c *client) RescanSpentContext(ctx context.Context) error {
+	return c.do(ctx, "rescan_spent", nil, nil)
 }
 
 func (c *client) StartMining(threads uint, background, ignorebattery bool) error {
+	return c.StartMiningContext(context.Background(), threads, background, ignorebattery)
+}
+
+func (c *client) StartMiningContext(ctx context.Context, threads uint, background, ignorebattery bool) error {
 	jin := struct {
 		Threads       uint `json:"threads_count"`
 		Background    bool `json:"do_background_mining"`
@@ -537,18 +894,26 @@ func (c *client) StartMining(threads uint, background, ignorebattery bool) error
 		background,
 		ignorebattery,
 	}
-	return c.do("start_mining", &jin, nil)
+	return c.do(ctx, "start_mining", &jin, nil)
 }
 
 func (c *client) StopMining() error {
-	return c.do("stop_mining", nil, nil)
+	return c.StopMiningContext(context.Background())
+}
+
+func (c *client) StopMiningContext(ctx context.Context) error {
+	return c.do(ctx, "stop_mining", nil, nil)
 }
 
 func (c *client) GetLanguages() (languages []string, err error) {
+	return c.GetLanguagesContext(context.Background())
+}
+
+func (c *client) GetLanguagesContext(ctx context.Context) (languages []string, err error) {
 	jd := struct {
 		Languages []string `json:"languages"`
 	}{}
-	err = c.do("get_languages", nil, &jd)
+	err = c.do(ctx, "get_languages", nil, &jd)
 	if err != nil {
 		return nil, err
 	}
@@ -557,6 +922,10 @@ func (c *client) GetLanguages() (languages []string, err error) {
 }
 
 func (c *client) CreateWallet(filename, password, language string) error {
+	return c.CreateWalletContext(context.Background(), filename, password, language)
+}
+
+func (c *client) CreateWalletContext(ctx context.Context, filename, password, language string) error {
 	jin := struct {
 		Filename string `json:"filename"`
 		Password string `json:"password"`
@@ -566,10 +935,14 @@ func (c *client) CreateWallet(filename, password, language string) error {
 		password,
 		language,
 	}
-	return c.do("create_wallet", &jin, nil)
+	return c.do(ctx, "create_wallet", &jin, nil)
 }
 
 func (c *client) OpenWallet(filename, password string) error {
+	return c.OpenWalletContext(context.Background(), filename, password)
+}
+
+func (c *client) OpenWalletContext(ctx context.Context, filename, password string) error {
 	jin := struct {
 		Filename string `json:"filename"`
 		Password string `json:"password"`
@@ -577,5 +950,215 @@ func (c *client) OpenWallet(filename, password string) error {
 		filename,
 		password,
 	}
-	return c.do("open_wallet", &jin, nil)
+	return c.do(ctx, "open_wallet", &jin, nil)
+}
+
+func (c *client) CreateAccount(label string) (index uint64, address string, err error) {
+	return c.CreateAccountContext(context.Background(), label)
+}
+
+func (c *client) CreateAccountContext(ctx context.Context, label string) (index uint64, address string, err error) {
+	jin := struct {
+		Label string `json:"label,omitempty"`
+	}{
+		label,
+	}
+	jd := struct {
+		Index   uint64 `json:"account_index"`
+		Address string `json:"address"`
+	}{}
+	err = c.do(ctx, "create_account", &jin, &jd)
+	if err != nil {
+		return 0, "", err
+	}
+	return jd.Index, jd.Address, nil
+}
+
+func (c *client) GetAccounts(tag string) (resp *GetAccountsResponse, err error) {
+	return c.GetAccountsContext(context.Background(), tag)
+}
+
+func (c *client) GetAccountsContext(ctx context.Context, tag string) (resp *GetAccountsResponse, err error) {
+	jin := struct {
+		Tag string `json:"tag,omitempty"`
+	}{
+		tag,
+	}
+	resp = &GetAccountsResponse{}
+	err = c.do(ctx, "get_accounts", &jin, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *client) LabelAccount(index uint64, label string) error {
+	return c.LabelAccountContext(context.Background(), index, label)
+}
+
+func (c *client) LabelAccountContext(ctx context.Context, index uint64, label string) error {
+	jin := struct {
+		Index uint64 `json:"account_index"`
+		Label string `json:"label"`
+	}{
+		index,
+		label,
+	}
+	return c.do(ctx, "label_account", &jin, nil)
+}
+
+func (c *client) GetAccountTags() (tags []AccountTag, err error) {
+	return c.GetAccountTagsContext(context.Background())
+}
+
+func (c *client) GetAccountTagsContext(ctx context.Context) (tags []AccountTag, err error) {
+	jd := struct {
+		AccountTags []AccountTag `json:"account_tags"`
+	}{}
+	err = c.do(ctx, "get_account_tags", nil, &jd)
+	if err != nil {
+		return nil, err
+	}
+	tags = jd.AccountTags
+	return
+}
+
+func (c *client) TagAccounts(tag string, accounts []uint64) error {
+	return c.TagAccountsContext(context.Background(), tag, accounts)
+}
+
+func (c *client) TagAccountsContext(ctx context.Context, tag string, accounts []uint64) error {
+	jin := struct {
+		Tag      string   `json:"tag"`
+		Accounts []uint64 `json:"accounts"`
+	}{
+		tag,
+		accounts,
+	}
+	return c.do(ctx, "tag_accounts", &jin, nil)
+}
+
+func (c *client) UntagAccounts(accounts []uint64) error {
+	return c.UntagAccountsContext(context.Background(), accounts)
+}
+
+func (c *client) UntagAccountsContext(ctx context.Context, accounts []uint64) error {
+	jin := struct {
+		Accounts []uint64 `json:"accounts"`
+	}{
+		accounts,
+	}
+	return c.do(ctx, "untag_accounts", &jin, nil)
+}
+
+func (c *client) SetAccountTagDescription(tag, description string) error {
+	return c.SetAccountTagDescriptionContext(context.Background(), tag, description)
+}
+
+func (c *client) SetAccountTagDescriptionContext(ctx context.Context, tag, description string) error {
+	jin := struct {
+		Tag         string `json:"tag"`
+		Description string `json:"description"`
+	}{
+		tag,
+		description,
+	}
+	return c.do(ctx, "set_account_tag_description", &jin, nil)
+}
+
+func (c *client) GetAddresses(accountIndex uint64, addressIndexes []uint64) (resp *GetAddressResponse, err error) {
+	return c.GetAddressesContext(context.Background(), accountIndex, addressIndexes)
+}
+
+func (c *client) GetAddressesContext(ctx context.Context, accountIndex uint64, addressIndexes []uint64) (resp *GetAddressResponse, err error) {
+	jin := struct {
+		AccountIndex   uint64   `json:"account_index"`
+		AddressIndexes []uint64 `json:"address_index,omitempty"`
+	}{
+		accountIndex,
+		addressIndexes,
+	}
+	resp = &GetAddressResponse{}
+	err = c.do(ctx, "get_address", &jin, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *client) CreateAddress(accountIndex uint64, label string, count uint64) (resp *CreateAddressResponse, err error) {
+	return c.CreateAddressContext(context.Background(), accountIndex, label, count)
+}
+
+func (c *client) CreateAddressContext(ctx context.Context, accountIndex uint64, label string, count uint64) (resp *CreateAddressResponse, err error) {
+	jin := struct {
+		AccountIndex uint64 `json:"account_index"`
+		Label        string `json:"label,omitempty"`
+		Count        uint64 `json:"count,omitempty"`
+	}{
+		accountIndex,
+		label,
+		count,
+	}
+	resp = &CreateAddressResponse{}
+	err = c.do(ctx, "create_address", &jin, resp)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *client) LabelAddress(index SubaddressIndex, label string) error {
+	return c.LabelAddressContext(context.Background(), index, label)
+}
+
+func (c *client) LabelAddressContext(ctx context.Context, index SubaddressIndex, label string) error {
+	jin := struct {
+		Index SubaddressIndex `json:"index"`
+		Label string          `json:"label"`
+	}{
+		index,
+		label,
+	}
+	return c.do(ctx, "label_address", &jin, nil)
+}
+
+func (c *client) GetAddressIndex(address string) (index SubaddressIndex, err error) {
+	return c.GetAddressIndexContext(context.Background(), address)
+}
+
+func (c *client) GetAddressIndexContext(ctx context.Context, address string) (index SubaddressIndex, err error) {
+	jin := struct {
+		Address string `json:"address"`
+	}{
+		address,
+	}
+	jd := struct {
+		Index SubaddressIndex `json:"index"`
+	}{}
+	err = c.do(ctx, "get_address_index", &jin, &jd)
+	if err != nil {
+		return SubaddressIndex{}, err
+	}
+	return jd.Index, nil
+}
+
+func (c *client) Call(method string, params, result interface{}) error {
+	return c.CallContext(context.Background(), method, params, result)
+}
+
+func (c *client) CallContext(ctx context.Context, method string, params, result interface{}) error {
+	return c.do(ctx, method, params, result)
+}
+
+func (c *client) CallRaw(method string, params interface{}) (result json.RawMessage, err error) {
+	return c.CallRawContext(context.Background(), method, params)
+}
+
+func (c *client) CallRawContext(ctx context.Context, method string, params interface{}) (result json.RawMessage, err error) {
+	err = c.do(ctx, method, params, &result)
+	if err != nil {
+		return nil, err
+	}
+	return
 }
@@ -1,10 +1,13 @@
 package walletrpc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -13,6 +16,11 @@ func TestClient(t *testing.T) {
 
 	testClientGetAddress(t)
 	testClientGetBalance(t)
+	testClientContextDeadline(t)
+	testClientCall(t)
+	testClientParseURI(t)
+	testClientAccounts(t)
+	testClientSubaddresses(t)
 }
 
 func testClientGetAddress(t *testing.T) {
@@ -76,14 +84,353 @@ func testClientGetBalance(t *testing.T) {
 	assert.Equal(t, uint64(10000000000000), unlocked)
 }
 
-//TODO: write more server stubs
-//
-//
+func testClientContextDeadline(t *testing.T) {
+	//
+	// server setup
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method == "rescan_blockchain" {
+				<-r.Context().Done()
+				return true
+			}
+			return false
+		},
+	})
+	defer sv0.Close()
+	//
+	// test starts here
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := rpccl.RescanBlockchainContext(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "%v", err)
+	assert.True(t, time.Since(start) < time.Second, "returned after %v", time.Since(start))
+}
+
+func testClientCall(t *testing.T) {
+	//
+	// server setup
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method == "get_version" {
+				r0 := struct {
+					Version uint64 `json:"version"`
+				}{
+					65539,
+				}
+				writerpcResponseOK(&r0, w)
+				return true
+			}
+			return false
+		},
+	})
+	defer sv0.Close()
+	//
+	// test starts here
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	jd := struct {
+		Version uint64 `json:"version"`
+	}{}
+	err := rpccl.Call("get_version", nil, &jd)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(65539), jd.Version)
+
+	raw, err := rpccl.CallRaw("get_version", nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"version":65539}`, string(raw))
+
+	_, err = rpccl.CallRaw("no_such_method", H{"a": 1})
+	iswerr, werr := GetWalletError(err)
+	assert.True(t, iswerr)
+	assert.Equal(t, ErrUnknown, werr.Code)
+}
+
+func testClientParseURI(t *testing.T) {
+	//
+	// server setup
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method != "parse_uri" {
+				return false
+			}
+			var jin struct {
+				URI string `json:"uri"`
+			}
+			assert.NoError(t, json.Unmarshal(*params, &jin))
+			if jin.URI == "monero:bad" {
+				writerpcResponseOK(json.RawMessage(`{}`), w)
+				return true
+			}
+			// the result of monero-wallet-rpc
+			writerpcResponseOK(json.RawMessage(`{
+  "uri": {
+    "address": "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+    "amount": 10,
+    "payment_id": "420fa29b2d9a49f5",
+    "recipient_name": "Monero Project donation address",
+    "tx_description": "Testing out the make_uri function."
+  }
+}`), w)
+			return true
+		},
+	})
+	defer sv0.Close()
+	//
+	// test starts here
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	parsed, err := rpccl.ParseURI("monero:55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt?tx_payment_id=420fa29b2d9a49f5&tx_amount=0.000000000010")
+	assert.NoError(t, err)
+	if assert.NotNil(t, parsed) {
+		assert.Equal(t, "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt", parsed.Address)
+		assert.Equal(t, uint64(10), parsed.Amount)
+		assert.Equal(t, "420fa29b2d9a49f5", parsed.PaymentID)
+		assert.Equal(t, "Monero Project donation address", parsed.RecipientName)
+		assert.Equal(t, "Testing out the make_uri function.", parsed.TxDescription)
+	}
+
+	parsed, err = rpccl.ParseURI("monero:bad")
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "%v", err)
+	assert.Nil(t, parsed)
+}
+
+// wireCall is the params a client must send to a method, and the literal
+// result monero-wallet-rpc answers with.
+type wireCall struct {
+	params string
+	result string
+}
+
+// wireTestServer checks the params of every call against calls and answers
+// with the recorded result.
+func wireTestServer(t *testing.T, calls map[string]wireCall) *httptest.Server {
+	return basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			call, ok := calls[method]
+			if !ok {
+				return false
+			}
+			got := "null"
+			if params != nil {
+				got = string(*params)
+			}
+			assert.JSONEq(t, call.params, got, method)
+			writerpcResponseOK(json.RawMessage(call.result), w)
+			return true
+		},
+	})
+}
+
+func testClientAccounts(t *testing.T) {
+	//
+	// server setup
+	sv0 := wireTestServer(t, map[string]wireCall{
+		"create_account": {
+			params: `{"label":"Secondary account"}`,
+			result: `{
+  "account_index": 1,
+  "address": "77Vx9cs1VPicFndSVgYUvTdLCJEZw9h81hXLMYsjBCXSJfUehLa9TDW3Ffh45SQa7xb6dUs18mpNxfUhQGqfwXPSMrvKhVp"
+}`,
+		},
+		"get_accounts": {
+			params: `{"tag":"myTag"}`,
+			result: `{
+  "subaddress_accounts": [{
+    "account_index": 0,
+    "balance": 157663195572433,
+    "base_address": "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+    "label": "Primary account",
+    "tag": "myTag",
+    "unlocked_balance": 157443303037455
+  },{
+    "account_index": 1,
+    "balance": 0,
+    "base_address": "77Vx9cs1VPicFndSVgYUvTdLCJEZw9h81hXLMYsjBCXSJfUehLa9TDW3Ffh45SQa7xb6dUs18mpNxfUhQGqfwXPSMrvKhVp",
+    "label": "Secondary account",
+    "tag": "myTag",
+    "unlocked_balance": 0
+  }],
+  "total_balance": 157663195572433,
+  "total_unlocked_balance": 157443303037455
+}`,
+		},
+		"label_account": {
+			params: `{"account_index":1,"label":"Income"}`,
+			result: `{}`,
+		},
+		"get_account_tags": {
+			params: `null`,
+			result: `{
+  "account_tags": [{
+    "accounts": [0,1],
+    "label": "Test tag",
+    "tag": "myTag"
+  }]
+}`,
+		},
+		"tag_accounts": {
+			params: `{"tag":"myTag","accounts":[0,1]}`,
+			result: `{}`,
+		},
+		"untag_accounts": {
+			params: `{"accounts":[1]}`,
+			result: `{}`,
+		},
+		"set_account_tag_description": {
+			params: `{"tag":"myTag","description":"Test tag"}`,
+			result: `{}`,
+		},
+	})
+	defer sv0.Close()
+	//
+	// test starts here
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	index, address, err := rpccl.CreateAccount("Secondary account")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), index)
+	assert.Equal(t, "77Vx9cs1VPicFndSVgYUvTdLCJEZw9h81hXLMYsjBCXSJfUehLa9TDW3Ffh45SQa7xb6dUs18mpNxfUhQGqfwXPSMrvKhVp", address)
+
+	accounts, err := rpccl.GetAccounts("myTag")
+	assert.NoError(t, err)
+	assert.Equal(t, &GetAccountsResponse{
+		SubaddressAccounts: []Account{
+			{
+				AccountIndex:    0,
+				Balance:         157663195572433,
+				BaseAddress:     "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+				Label:           "Primary account",
+				Tag:             "myTag",
+				UnlockedBalance: 157443303037455,
+			},
+			{
+				AccountIndex: 1,
+				BaseAddress:  "77Vx9cs1VPicFndSVgYUvTdLCJEZw9h81hXLMYsjBCXSJfUehLa9TDW3Ffh45SQa7xb6dUs18mpNxfUhQGqfwXPSMrvKhVp",
+				Label:        "Secondary account",
+				Tag:          "myTag",
+			},
+		},
+		TotalBalance:         157663195572433,
+		TotalUnlockedBalance: 157443303037455,
+	}, accounts)
+
+	assert.NoError(t, rpccl.LabelAccount(1, "Income"))
+
+	tags, err := rpccl.GetAccountTags()
+	assert.NoError(t, err)
+	assert.Equal(t, []AccountTag{{Tag: "myTag", Label: "Test tag", Accounts: []uint64{0, 1}}}, tags)
+
+	assert.NoError(t, rpccl.TagAccounts("myTag", []uint64{0, 1}))
+	assert.NoError(t, rpccl.UntagAccounts([]uint64{1}))
+	assert.NoError(t, rpccl.SetAccountTagDescription("myTag", "Test tag"))
+}
+
+func testClientSubaddresses(t *testing.T) {
+	//
+	// server setup
+	sv0 := wireTestServer(t, map[string]wireCall{
+		"get_address": {
+			params: `{"account_index":0,"address_index":[0,1,4]}`,
+			result: `{
+  "address": "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+  "addresses": [{
+    "address": "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+    "address_index": 0,
+    "label": "Primary account",
+    "used": true
+  },{
+    "address": "7BnERTpvL5MbCLtj5n9No7J5oE5hHiB3tVCK5cjSvCsYWD2WRJLFuWeKTLiXo5QJqt2ZwUaLy2Vh1Ad51K7FNgqcHgjW85o",
+    "address_index": 1,
+    "label": "",
+    "used": true
+  },{
+    "address": "77xa6Dha7kzCQuvmd8iB5VYoMkdenwCNRU9khGhExXQ8KLL3z1N1ZATBD1sFPenyHWT9cm4fVFnCAUApY53peuoZFtwZiw5",
+    "address_index": 4,
+    "label": "test2",
+    "used": true
+  }]
+}`,
+		},
+		"create_address": {
+			params: `{"account_index":0,"label":"new-sub","count":2}`,
+			result: `{
+  "address": "7BG5jr9QS5sGMdpbBrZEwVLZjSKJGJBsXdZLt8wiXFhfVRXX6hxoVBRjCVMDaYHNgXrEcvCrJJbXb4bcgb9B2PNzE9BsMHR",
+  "address_index": 5,
+  "address_indices": [5,6],
+  "addresses": [
+    "7BG5jr9QS5sGMdpbBrZEwVLZjSKJGJBsXdZLt8wiXFhfVRXX6hxoVBRjCVMDaYHNgXrEcvCrJJbXb4bcgb9B2PNzE9BsMHR",
+    "77xa6Dha7kzCQuvmd8iB5VYoMkdenwCNRU9khGhExXQ8KLL3z1N1ZATBD1sFPenyHWT9cm4fVFnCAUApY53peuoZFtwZiw5"
+  ]
+}`,
+		},
+		"label_address": {
+			params: `{"index":{"major":0,"minor":5},"label":"myLabel"}`,
+			result: `{}`,
+		},
+		"get_address_index": {
+			params: `{"address":"7BnERTpvL5MbCLtj5n9No7J5oE5hHiB3tVCK5cjSvCsYWD2WRJLFuWeKTLiXo5QJqt2ZwUaLy2Vh1Ad51K7FNgqcHgjW85o"}`,
+			result: `{
+  "index": {
+    "major": 1,
+    "minor": 4
+  }
+}`,
+		},
+	})
+	defer sv0.Close()
+	//
+	// test starts here
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	addrs, err := rpccl.GetAddresses(0, []uint64{0, 1, 4})
+	assert.NoError(t, err)
+	assert.Equal(t, &GetAddressResponse{
+		Address: "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt",
+		Addresses: []Subaddress{
+			{Address: "55LTR8KniP4LQGJSPtbYDacR7dz8RBFnsfAKMaMuwUNYX6aQbBcovzDPyrQF9KXF9tVU6Xk3K8no1BywnJX6GvZX8yJsXvt", AddressIndex: 0, Label: "Primary account", Used: true},
+			{Address: "7BnERTpvL5MbCLtj5n9No7J5oE5hHiB3tVCK5cjSvCsYWD2WRJLFuWeKTLiXo5QJqt2ZwUaLy2Vh1Ad51K7FNgqcHgjW85o", AddressIndex: 1, Used: true},
+			{Address: "77xa6Dha7kzCQuvmd8iB5VYoMkdenwCNRU9khGhExXQ8KLL3z1N1ZATBD1sFPenyHWT9cm4fVFnCAUApY53peuoZFtwZiw5", AddressIndex: 4, Label: "test2", Used: true},
+		},
+	}, addrs)
+
+	created, err := rpccl.CreateAddress(0, "new-sub", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &CreateAddressResponse{
+		Address:        "7BG5jr9QS5sGMdpbBrZEwVLZjSKJGJBsXdZLt8wiXFhfVRXX6hxoVBRjCVMDaYHNgXrEcvCrJJbXb4bcgb9B2PNzE9BsMHR",
+		AddressIndex:   5,
+		AddressIndices: []uint64{5, 6},
+		Addresses: []string{
+			"7BG5jr9QS5sGMdpbBrZEwVLZjSKJGJBsXdZLt8wiXFhfVRXX6hxoVBRjCVMDaYHNgXrEcvCrJJbXb4bcgb9B2PNzE9BsMHR",
+			"77xa6Dha7kzCQuvmd8iB5VYoMkdenwCNRU9khGhExXQ8KLL3z1N1ZATBD1sFPenyHWT9cm4fVFnCAUApY53peuoZFtwZiw5",
+		},
+	}, created)
+
+	assert.NoError(t, rpccl.LabelAddress(SubaddressIndex{Major: 0, Minor: 5}, "myLabel"))
+
+	index, err := rpccl.GetAddressIndex("7BnERTpvL5MbCLtj5n9No7J5oE5hHiB3tVCK5cjSvCsYWD2WRJLFuWeKTLiXo5QJqt2ZwUaLy2Vh1Ad51K7FNgqcHgjW85o")
+	assert.NoError(t, err)
+	assert.Equal(t, SubaddressIndex{Major: 1, Minor: 4}, index)
+}
+
+// These stubs only cover what the tests of this package need. The
+// walletrpctest package has a fake server for every method.
 
 type testfn = func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool
 
 func basicTestServer(tests []testfn) *httptest.Server {
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return httptest.NewServer(basicTestHandler(tests))
+}
+
+func basicTestHandler(tests []testfn) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.RequestURI != "/json_rpc" {
 			http.Error(w, "not found", http.StatusNotFound)
 			return
@@ -97,14 +444,21 @@ func basicTestServer(tests []testfn) *httptest.Server {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
+		rw := &rpcTestWriter{w, c.Id}
 		for _, v := range tests {
-			if v(c.Method, c.Params, w, r) {
+			if v(c.Method, c.Params, rw, r) {
 				return
 			}
 		}
 		// return method not found
-		writerpcResponseError(ErrUnknown, "test this in curl with the real rpc", w)
-	}))
+		writerpcResponseError(ErrUnknown, "test this in curl with the real rpc", rw)
+	})
+}
+
+// rpcTestWriter carries the id of the request being answered.
+type rpcTestWriter struct {
+	http.ResponseWriter
+	id uint64
 }
 
 func writerpcResponseOK(result interface{}, w http.ResponseWriter) {
@@ -112,6 +466,9 @@ func writerpcResponseOK(result interface{}, w http.ResponseWriter) {
 		Version: "2.0",
 		Result:  result,
 	}
+	if rw, ok := w.(*rpcTestWriter); ok {
+		r.Id = rw.id
+	}
 	v, err := json.Marshal(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -129,6 +486,9 @@ func writerpcResponseError(code ErrorCode, message string, w http.ResponseWriter
 			Message: message,
 		},
 	}
+	if rw, ok := w.(*rpcTestWriter); ok {
+		r.Id = rw.id
+	}
 	v, err := json.Marshal(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -153,6 +513,7 @@ type clientRequest struct {
 // clientResponse represents a JSON-RPC response returned to a client.
 type clientResponse struct {
 	Version string      `json:"jsonrpc"`
+	Id      uint64      `json:"id"`
 	Result  interface{} `json:"result"`
 	Error   interface{} `json:"error"`
 }
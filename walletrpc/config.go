@@ -1,7 +1,11 @@
 package walletrpc
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 // Config holds the configuration of a monero rpc client.
@@ -9,4 +13,107 @@ type Config struct {
 	Address       string
 	CustomHeaders map[string]string
 	Transport     http.RoundTripper
+	// Username and Password enable HTTP digest authentication, as used by
+	// monero-wallet-rpc --rpc-login username:password.
+	Username string
+	Password string
+	// TLS configures the CA, certificate pinning and client certificate
+	// used to reach a monero-wallet-rpc started with --rpc-ssl. It can't be
+	// combined with a Transport other than *http.Transport.
+	TLS *TLSConfig
+	// Proxy is the URL of a SOCKS5 proxy, such as Tor
+	// (socks5h://127.0.0.1:9050). With socks5h://, host names are resolved
+	// by the proxy, so .onion addresses can be used; socks5:// resolves
+	// them locally and only sends IP addresses to the proxy. With Tor,
+	// different user:password pairs in the URL get isolated circuits.
+	Proxy string
+	// Timeout limits the time of each request, including connecting and
+	// reading the response. Zero means no timeout.
+	Timeout time.Duration
+	// Retry enables retries of transient failures. When nil, every call
+	// makes a single attempt.
+	Retry *RetryPolicy
+	// Interceptors run around every call, the first one being the
+	// outermost. See Interceptor.
+	Interceptors []Interceptor
+	// Logger, when set, receives a record of every call with passwords,
+	// keys and other secrets redacted.
+	Logger Logger
+	// Queue, when set, orders and rate limits the requests sent to the
+	// endpoint. Share the same Queue between clients of the same endpoint.
+	Queue *Queue
+	// MaxResponseSize is the largest response body accepted, in bytes.
+	// Zero means DefaultMaxResponseSize and a negative value means no
+	// limit. Streamed responses are held to the same limit.
+	MaxResponseSize int64
+	// StrictDecoding makes calls fail with an UnknownFieldError when the
+	// response has fields this package does not know about. Use it to
+	// detect schema changes between monero releases.
+	StrictDecoding bool
+}
+
+// ConfigError reports an invalid configuration value.
+type ConfigError struct {
+	// Field is the name of the setting, as used by ParseDSN,
+	// ConfigFromEnv and LoadConfigFile.
+	Field string
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("walletrpc: invalid %v: %v", e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks that cfg describes a usable client. New does not call
+// it; a client built from an invalid Config fails on every call instead.
+func (cfg Config) Validate() error {
+	u, err := url.Parse(cfg.Address)
+	if err != nil {
+		return &ConfigError{"address", urlError(err)}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &ConfigError{"address", fmt.Errorf("unsupported scheme %q", u.Scheme)}
+	}
+	if u.Host == "" {
+		return &ConfigError{"address", errors.New("missing host")}
+	}
+	if cfg.TLS != nil {
+		if u.Scheme != "https" {
+			return &ConfigError{"tls", errors.New("TLS options require an https address")}
+		}
+		if _, err := cfg.TLS.Build(); err != nil {
+			return &ConfigError{"tls", err}
+		}
+	}
+	if cfg.Proxy != "" {
+		if _, err := newSOCKSDialer(cfg.Proxy); err != nil {
+			return &ConfigError{"proxy", urlError(err)}
+		}
+	}
+	if cfg.TLS != nil || cfg.Proxy != "" {
+		if _, err := baseTransport(cfg.Transport); err != nil {
+			return &ConfigError{"transport", err}
+		}
+	}
+	if cfg.Timeout < 0 {
+		return &ConfigError{"timeout", errors.New("must not be negative")}
+	}
+	if cfg.Retry != nil && cfg.Retry.MaxAttempts < 0 {
+		return &ConfigError{"retry_attempts", errors.New("must not be negative")}
+	}
+	return nil
+}
+
+// urlError strips the URL, which may hold a password, from url.Parse
+// errors.
+func urlError(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+	return err
 }
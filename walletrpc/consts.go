@@ -1,6 +1,7 @@
 package walletrpc
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/gorilla/rpc/v2/json2"
@@ -10,7 +11,7 @@ import (
 type H map[string]interface{}
 
 // ErrorCode is a monero-wallet-rpc error code.
-// Copied from https://github.com/monero-project/monero/blob/release-v0.11.0.0/src/wallet/wallet_rpc_server_error_codes.h
+// Copied from https://github.com/monero-project/monero/blob/master/src/wallet/wallet_rpc_server_error_codes.h
 type ErrorCode int
 
 const (
@@ -40,33 +41,255 @@ const (
 	ErrWrongIndex ErrorCode = -12
 	// ErrNotOpen - E_NOT_OPEN
 	ErrNotOpen ErrorCode = -13
+	// ErrAccountIndexOutOfBounds - E_ACCOUNT_INDEX_OUT_OF_BOUNDS
+	ErrAccountIndexOutOfBounds ErrorCode = -14
+	// ErrAddressIndexOutOfBounds - E_ADDRESS_INDEX_OUT_OF_BOUNDS
+	ErrAddressIndexOutOfBounds ErrorCode = -15
+	// ErrTxNotPossible - E_TX_NOT_POSSIBLE
+	ErrTxNotPossible ErrorCode = -16
+	// ErrNotEnoughMoney - E_NOT_ENOUGH_MONEY
+	ErrNotEnoughMoney ErrorCode = -17
+	// ErrTxTooLarge - E_TX_TOO_LARGE
+	ErrTxTooLarge ErrorCode = -18
+	// ErrNotEnoughOutsToMix - E_NOT_ENOUGH_OUTS_TO_MIX
+	ErrNotEnoughOutsToMix ErrorCode = -19
+	// ErrZeroDestination - E_ZERO_DESTINATION
+	ErrZeroDestination ErrorCode = -20
+	// ErrWalletAlreadyExists - E_WALLET_ALREADY_EXISTS
+	ErrWalletAlreadyExists ErrorCode = -21
+	// ErrInvalidPassword - E_INVALID_PASSWORD
+	ErrInvalidPassword ErrorCode = -22
+	// ErrNoWalletDir - E_NO_WALLET_DIR
+	ErrNoWalletDir ErrorCode = -23
+	// ErrNoTxKey - E_NO_TXKEY
+	ErrNoTxKey ErrorCode = -24
+	// ErrWrongKey - E_WRONG_KEY
+	ErrWrongKey ErrorCode = -25
+	// ErrBadHex - E_BAD_HEX
+	ErrBadHex ErrorCode = -26
+	// ErrBadTxMetadata - E_BAD_TX_METADATA
+	ErrBadTxMetadata ErrorCode = -27
+	// ErrAlreadyMultisig - E_ALREADY_MULTISIG
+	ErrAlreadyMultisig ErrorCode = -28
+	// ErrWatchOnly - E_WATCH_ONLY
+	ErrWatchOnly ErrorCode = -29
+	// ErrBadMultisigInfo - E_BAD_MULTISIG_INFO
+	ErrBadMultisigInfo ErrorCode = -30
+	// ErrNotMultisig - E_NOT_MULTISIG
+	ErrNotMultisig ErrorCode = -31
+	// ErrWrongLR - E_WRONG_LR
+	ErrWrongLR ErrorCode = -32
+	// ErrThresholdNotReached - E_THRESHOLD_NOT_REACHED
+	ErrThresholdNotReached ErrorCode = -33
+	// ErrBadMultisigTxData - E_BAD_MULTISIG_TX_DATA
+	ErrBadMultisigTxData ErrorCode = -34
+	// ErrMultisigSignature - E_MULTISIG_SIGNATURE
+	ErrMultisigSignature ErrorCode = -35
+	// ErrMultisigSubmission - E_MULTISIG_SUBMISSION
+	ErrMultisigSubmission ErrorCode = -36
+	// ErrNotEnoughUnlockedMoney - E_NOT_ENOUGH_UNLOCKED_MONEY
+	ErrNotEnoughUnlockedMoney ErrorCode = -37
+	// ErrNoDaemonConnection - E_NO_DAEMON_CONNECTION
+	ErrNoDaemonConnection ErrorCode = -38
+	// ErrBadUnsignedTxData - E_BAD_UNSIGNED_TX_DATA
+	ErrBadUnsignedTxData ErrorCode = -39
+	// ErrBadSignedTxData - E_BAD_SIGNED_TX_DATA
+	ErrBadSignedTxData ErrorCode = -40
+	// ErrSignedSubmission - E_SIGNED_SUBMISSION
+	ErrSignedSubmission ErrorCode = -41
+	// ErrSignUnsigned - E_SIGN_UNSIGNED
+	ErrSignUnsigned ErrorCode = -42
+	// ErrNonDeterministic - E_NON_DETERMINISTIC
+	ErrNonDeterministic ErrorCode = -43
+	// ErrInvalidLogLevel - E_INVALID_LOG_LEVEL
+	ErrInvalidLogLevel ErrorCode = -44
+	// ErrAttributeNotFound - E_ATTRIBUTE_NOT_FOUND
+	ErrAttributeNotFound ErrorCode = -45
+	// ErrZeroAmount - E_ZERO_AMOUNT
+	ErrZeroAmount ErrorCode = -46
+	// ErrInvalidSignatureType - E_INVALID_SIGNATURE_TYPE
+	ErrInvalidSignatureType ErrorCode = -47
+	// ErrDisabled - E_DISABLED
+	ErrDisabled ErrorCode = -48
+	// ErrProxyAlreadyDefined - E_PROXY_ALREADY_DEFINED
+	ErrProxyAlreadyDefined ErrorCode = -49
+	// ErrNonzeroUnlockTime - E_NONZERO_UNLOCK_TIME
+	ErrNonzeroUnlockTime ErrorCode = -50
 )
 
+// Error implements the error interface, so the ErrorCode constants can be
+// used as errors.Is targets:
+//
+//	if errors.Is(err, walletrpc.ErrNotOpen) {
+//		...
+//	}
+func (code ErrorCode) Error() string {
+	if info, ok := errorCodes[code]; ok {
+		return fmt.Sprintf("monero-wallet-rpc error %d (%v)", int(code), info.name)
+	}
+	return fmt.Sprintf("monero-wallet-rpc error %d", int(code))
+}
+
+// Class returns the ErrorClass of the code. Unknown codes are reported as
+// ErrorClassFatal, except for the standard JSON-RPC request errors.
+func (code ErrorCode) Class() ErrorClass {
+	if info, ok := errorCodes[code]; ok {
+		return info.class
+	}
+	switch json2.ErrorCode(code) {
+	case json2.E_PARSE, json2.E_INVALID_REQ, json2.E_NO_METHOD, json2.E_BAD_PARAMS:
+		return ErrorClassCaller
+	}
+	return ErrorClassFatal
+}
+
+// ErrorClass groups error codes by what the caller can do about them.
+type ErrorClass int
+
+const (
+	// ErrorClassFatal errors are unexpected; alert someone.
+	ErrorClassFatal ErrorClass = iota
+	// ErrorClassRetryable errors are transient; the same call can be
+	// retried later.
+	ErrorClassRetryable
+	// ErrorClassCaller errors are caused by invalid arguments; retrying the
+	// same call will fail again.
+	ErrorClassCaller
+	// ErrorClassWalletState errors depend on the state of the wallet (not
+	// open, watch-only, not enough money...).
+	ErrorClassWalletState
+)
+
+func (class ErrorClass) String() string {
+	switch class {
+	case ErrorClassFatal:
+		return "fatal"
+	case ErrorClassRetryable:
+		return "retryable"
+	case ErrorClassCaller:
+		return "caller"
+	case ErrorClassWalletState:
+		return "wallet_state"
+	}
+	return "unknown"
+}
+
+var errorCodes = map[ErrorCode]struct {
+	name  string
+	class ErrorClass
+}{
+	ErrUnknown:                 {"UNKNOWN_ERROR", ErrorClassFatal},
+	ErrWrongAddress:            {"WRONG_ADDRESS", ErrorClassCaller},
+	ErrDaemonIsBusy:            {"DAEMON_IS_BUSY", ErrorClassRetryable},
+	ErrGenericTransferError:    {"GENERIC_TRANSFER_ERROR", ErrorClassFatal},
+	ErrWrongPaymentID:          {"WRONG_PAYMENT_ID", ErrorClassCaller},
+	ErrTransferType:            {"TRANSFER_TYPE", ErrorClassCaller},
+	ErrDenied:                  {"DENIED", ErrorClassFatal},
+	ErrWrongTxID:               {"WRONG_TXID", ErrorClassCaller},
+	ErrWrongSignature:          {"WRONG_SIGNATURE", ErrorClassCaller},
+	ErrWrongKeyImage:           {"WRONG_KEY_IMAGE", ErrorClassCaller},
+	ErrWrongURI:                {"WRONG_URI", ErrorClassCaller},
+	ErrWrongIndex:              {"WRONG_INDEX", ErrorClassCaller},
+	ErrNotOpen:                 {"NOT_OPEN", ErrorClassWalletState},
+	ErrAccountIndexOutOfBounds: {"ACCOUNT_INDEX_OUT_OF_BOUNDS", ErrorClassCaller},
+	ErrAddressIndexOutOfBounds: {"ADDRESS_INDEX_OUT_OF_BOUNDS", ErrorClassCaller},
+	ErrTxNotPossible:           {"TX_NOT_POSSIBLE", ErrorClassWalletState},
+	ErrNotEnoughMoney:          {"NOT_ENOUGH_MONEY", ErrorClassWalletState},
+	ErrTxTooLarge:              {"TX_TOO_LARGE", ErrorClassCaller},
+	ErrNotEnoughOutsToMix:      {"NOT_ENOUGH_OUTS_TO_MIX", ErrorClassCaller},
+	ErrZeroDestination:         {"ZERO_DESTINATION", ErrorClassCaller},
+	ErrWalletAlreadyExists:     {"WALLET_ALREADY_EXISTS", ErrorClassCaller},
+	ErrInvalidPassword:         {"INVALID_PASSWORD", ErrorClassCaller},
+	ErrNoWalletDir:             {"NO_WALLET_DIR", ErrorClassFatal},
+	ErrNoTxKey:                 {"NO_TXKEY", ErrorClassWalletState},
+	ErrWrongKey:                {"WRONG_KEY", ErrorClassCaller},
+	ErrBadHex:                  {"BAD_HEX", ErrorClassCaller},
+	ErrBadTxMetadata:           {"BAD_TX_METADATA", ErrorClassCaller},
+	ErrAlreadyMultisig:         {"ALREADY_MULTISIG", ErrorClassWalletState},
+	ErrWatchOnly:               {"WATCH_ONLY", ErrorClassWalletState},
+	ErrBadMultisigInfo:         {"BAD_MULTISIG_INFO", ErrorClassCaller},
+	ErrNotMultisig:             {"NOT_MULTISIG", ErrorClassWalletState},
+	ErrWrongLR:                 {"WRONG_LR", ErrorClassCaller},
+	ErrThresholdNotReached:     {"THRESHOLD_NOT_REACHED", ErrorClassWalletState},
+	ErrBadMultisigTxData:       {"BAD_MULTISIG_TX_DATA", ErrorClassCaller},
+	ErrMultisigSignature:       {"MULTISIG_SIGNATURE", ErrorClassFatal},
+	ErrMultisigSubmission:      {"MULTISIG_SUBMISSION", ErrorClassFatal},
+	ErrNotEnoughUnlockedMoney:  {"NOT_ENOUGH_UNLOCKED_MONEY", ErrorClassWalletState},
+	ErrNoDaemonConnection:      {"NO_DAEMON_CONNECTION", ErrorClassRetryable},
+	ErrBadUnsignedTxData:       {"BAD_UNSIGNED_TX_DATA", ErrorClassCaller},
+	ErrBadSignedTxData:         {"BAD_SIGNED_TX_DATA", ErrorClassCaller},
+	ErrSignedSubmission:        {"SIGNED_SUBMISSION", ErrorClassFatal},
+	ErrSignUnsigned:            {"SIGN_UNSIGNED", ErrorClassFatal},
+	ErrNonDeterministic:        {"NON_DETERMINISTIC", ErrorClassWalletState},
+	ErrInvalidLogLevel:         {"INVALID_LOG_LEVEL", ErrorClassCaller},
+	ErrAttributeNotFound:       {"ATTRIBUTE_NOT_FOUND", ErrorClassCaller},
+	ErrZeroAmount:              {"ZERO_AMOUNT", ErrorClassCaller},
+	ErrInvalidSignatureType:    {"INVALID_SIGNATURE_TYPE", ErrorClassCaller},
+	ErrDisabled:                {"DISABLED", ErrorClassFatal},
+	ErrProxyAlreadyDefined:     {"PROXY_ALREADY_DEFINED", ErrorClassWalletState},
+	ErrNonzeroUnlockTime:       {"NONZERO_UNLOCK_TIME", ErrorClassCaller},
+}
+
 // WalletError is the error structured returned by the monero-wallet-rpc
 type WalletError struct {
-	Code    ErrorCode `json:"code"`
-	Message string    `json:"message"`
+	Code    ErrorCode   `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 func (we *WalletError) Error() string {
-	return fmt.Sprintf("%v: %v", we.Code, we.Message)
+	return fmt.Sprintf("%d: %v", int(we.Code), we.Message)
+}
+
+// Is reports whether target is the ErrorCode (or a WalletError with the
+// same code) of this error.
+func (we *WalletError) Is(target error) bool {
+	switch t := target.(type) {
+	case ErrorCode:
+		return we.Code == t
+	case *WalletError:
+		return t != nil && we.Code == t.Code
+	}
+	return false
 }
 
 // GetWalletError checks if an erro interface is a wallet-rpc error.
+// Wrapped errors are unwrapped.
 func GetWalletError(err error) (isWalletError bool, werr *WalletError) {
 	if err == nil {
 		return false, nil
 	}
-	gerr, ok := err.(*json2.Error)
-	if !ok {
+	if errors.As(err, &werr) {
+		return true, werr
+	}
+	var gerr *json2.Error
+	if !errors.As(err, &gerr) {
 		return false, nil
 	}
-	werr = &WalletError{
+	return true, walletErrorFromJSON2(gerr)
+}
+
+func walletErrorFromJSON2(gerr *json2.Error) *WalletError {
+	return &WalletError{
 		Code:    ErrorCode(gerr.Code),
 		Message: gerr.Message,
+		Data:    gerr.Data,
+	}
+}
+
+// HTTPError is returned when monero-wallet-rpc (or a proxy in front of it)
+// responds with a status other than 200 OK.
+type HTTPError struct {
+	StatusCode int
+	// Body is an excerpt of the response body.
+	Body string
+}
+
+func (he *HTTPError) Error() string {
+	if he.Body == "" {
+		return fmt.Sprintf("http status %v", he.StatusCode)
 	}
-	isWalletError = true
-	return
+	return fmt.Sprintf("http status %v: %v", he.StatusCode, he.Body)
 }
 
 // Priority represents a transaction priority
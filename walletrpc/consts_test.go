@@ -0,0 +1,86 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletErrorIs(t *testing.T) {
+	var err error = &WalletError{Code: ErrNotOpen, Message: "No wallet file"}
+	assert.True(t, errors.Is(err, ErrNotOpen))
+	assert.False(t, errors.Is(err, ErrDenied))
+
+	wrapped := fmt.Errorf("checking balance: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrNotOpen))
+	var werr *WalletError
+	assert.True(t, errors.As(wrapped, &werr))
+	assert.Equal(t, "No wallet file", werr.Message)
+
+	iswerr, werr := GetWalletError(wrapped)
+	assert.True(t, iswerr)
+	assert.Equal(t, ErrNotOpen, werr.Code)
+
+	iswerr, werr = GetWalletError(&json2.Error{Code: -3, Message: "busy", Data: "x"})
+	assert.True(t, iswerr)
+	assert.Equal(t, ErrDaemonIsBusy, werr.Code)
+	assert.Equal(t, "x", werr.Data)
+
+	iswerr, _ = GetWalletError(errors.New("EOF"))
+	assert.False(t, iswerr)
+}
+
+func TestClientErrors(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "getbalance":
+				writerpcResponseError(ErrNotOpen, "No wallet file", w)
+			case "getheight":
+				http.Error(w, "upstream exploded", http.StatusBadGateway)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	_, _, err := rpccl.GetBalance()
+	assert.True(t, errors.Is(err, ErrNotOpen))
+	werr, ok := err.(*WalletError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "No wallet file", werr.Message)
+	}
+
+	_, err = rpccl.GetHeight()
+	var herr *HTTPError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.Equal(t, http.StatusBadGateway, herr.StatusCode)
+		assert.Equal(t, "upstream exploded", herr.Body)
+	}
+}
+
+func TestErrorCodeClass(t *testing.T) {
+	for code := ErrNonzeroUnlockTime; code <= ErrUnknown; code++ {
+		_, ok := errorCodes[code]
+		assert.True(t, ok, "missing error code %d", code)
+	}
+	assert.Equal(t, ErrorClassRetryable, ErrDaemonIsBusy.Class())
+	assert.Equal(t, ErrorClassRetryable, ErrNoDaemonConnection.Class())
+	assert.Equal(t, ErrorClassWalletState, ErrNotOpen.Class())
+	assert.Equal(t, ErrorClassWalletState, ErrNotMultisig.Class())
+	assert.Equal(t, ErrorClassCaller, ErrAddressIndexOutOfBounds.Class())
+	assert.Equal(t, ErrorClassCaller, ErrorCode(json2.E_NO_METHOD).Class())
+	assert.Equal(t, ErrorClassFatal, ErrorCode(-9999).Class())
+	assert.Equal(t, "wallet_state", ErrNotOpen.Class().String())
+	assert.Equal(t, "monero-wallet-rpc error -21 (WALLET_ALREADY_EXISTS)", ErrWalletAlreadyExists.Error())
+}
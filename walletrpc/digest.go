@@ -0,0 +1,306 @@
+package walletrpc
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// digestTransport is a http.RoundTripper that performs HTTP digest
+// authentication (RFC 7616), as required by monero-wallet-rpc when started
+// with --rpc-login.
+type digestTransport struct {
+	username string
+	password string
+	next     http.RoundTripper
+
+	mu   sync.Mutex
+	chal *digestChallenge
+	nc   uint32
+}
+
+func newDigestTransport(username, password string, next http.RoundTripper) *digestTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &digestTransport{
+		username: username,
+		password: password,
+		next:     next,
+	}
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	stale     bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *digestTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	chal, nc := t.challenge()
+	resp, err := t.send(req, chal, nc)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	next := parseDigestChallenges(resp.Header["Www-Authenticate"])
+	if next == nil {
+		return resp, nil
+	}
+	if chal != nil && !next.stale && next.nonce == chal.nonce {
+		// the credentials were rejected, not the nonce
+		return resp, nil
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body can't be sent twice
+		return resp, nil
+	}
+	drainBody(resp.Body)
+	nc = t.setChallenge(next)
+	return t.send(req, next, nc)
+}
+
+// challenge returns the cached challenge and the next nonce count.
+func (t *digestTransport) challenge() (*digestChallenge, uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.chal == nil {
+		return nil, 0
+	}
+	t.nc++
+	return t.chal, t.nc
+}
+
+// setChallenge caches a new challenge and returns the first nonce count.
+func (t *digestTransport) setChallenge(chal *digestChallenge) uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.chal == nil || t.chal.nonce != chal.nonce {
+		t.chal = chal
+		t.nc = 0
+	}
+	t.nc++
+	return t.nc
+}
+
+func (t *digestTransport) send(req *http.Request, chal *digestChallenge, nc uint32) (*http.Response, error) {
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r2.Header[k] = v
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r2.Body = body
+	}
+	if chal != nil {
+		auth, err := t.authorization(r2, chal, nc)
+		if err != nil {
+			return nil, err
+		}
+		r2.Header.Set("Authorization", auth)
+	}
+	return t.next.RoundTrip(r2)
+}
+
+func (t *digestTransport) authorization(req *http.Request, chal *digestChallenge, nc uint32) (string, error) {
+	var h func() hash.Hash
+	algo := strings.ToUpper(chal.algorithm)
+	switch strings.TrimSuffix(algo, "-SESS") {
+	case "", "MD5":
+		h = md5.New
+	case "SHA-256":
+		h = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %v", chal.algorithm)
+	}
+	hexh := func(s string) string {
+		hh := h()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+	cnonce := newCnonce()
+	ncs := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := hexh(t.username + ":" + chal.realm + ":" + t.password)
+	if strings.HasSuffix(algo, "-SESS") {
+		ha1 = hexh(ha1 + ":" + chal.nonce + ":" + cnonce)
+	}
+	ha2 := hexh(req.Method + ":" + uri)
+
+	var response string
+	if chal.qop == "" {
+		response = hexh(ha1 + ":" + chal.nonce + ":" + ha2)
+	} else {
+		response = hexh(ha1 + ":" + chal.nonce + ":" + ncs + ":" + cnonce + ":" + chal.qop + ":" + ha2)
+	}
+
+	var qerr error
+	quoted := func(key, val string) string {
+		q, err := quoteString(val)
+		if err != nil && qerr == nil {
+			qerr = fmt.Errorf("walletrpc: digest %v: %v", key, err)
+		}
+		return key + "=" + q
+	}
+	parts := []string{
+		quoted("username", t.username),
+		quoted("realm", chal.realm),
+		quoted("nonce", chal.nonce),
+		quoted("uri", uri),
+		quoted("response", response),
+	}
+	if chal.algorithm != "" {
+		parts = append(parts, "algorithm="+chal.algorithm)
+	}
+	if chal.qop != "" {
+		parts = append(parts, "qop="+chal.qop, "nc="+ncs, quoted("cnonce", cnonce))
+	}
+	if chal.opaque != "" {
+		parts = append(parts, quoted("opaque", chal.opaque))
+	}
+	if qerr != nil {
+		return "", qerr
+	}
+	return "Digest " + strings.Join(parts, ", "), nil
+}
+
+// quoteString returns s as a RFC 7230 quoted-string: quotes and backslashes
+// are escaped, and bytes above 0x7f are kept as they are. Control
+// characters other than tab can't be sent in a header.
+func quoteString(s string) (string, error) {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\t' || (c >= 0x20 && c != 0x7f):
+			b.WriteByte(c)
+		default:
+			return "", fmt.Errorf("invalid character %q", c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+func newCnonce() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// parseDigestChallenges picks the strongest supported digest challenge out
+// of the WWW-Authenticate headers of a response.
+func parseDigestChallenges(headers []string) *digestChallenge {
+	var best *digestChallenge
+	bestScore := 0
+	for _, v := range headers {
+		if len(v) < 7 || !strings.EqualFold(v[:7], "digest ") {
+			continue
+		}
+		params := parseAuthParams(v[7:])
+		chal := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			stale:     strings.EqualFold(params["stale"], "true"),
+		}
+		if chal.nonce == "" {
+			continue
+		}
+		if qop, ok := params["qop"]; ok {
+			for _, q := range strings.Split(qop, ",") {
+				if strings.TrimSpace(q) == "auth" {
+					chal.qop = "auth"
+				}
+			}
+			if chal.qop == "" {
+				// only auth-int is offered
+				continue
+			}
+		}
+		score := 0
+		switch strings.ToUpper(chal.algorithm) {
+		case "SHA-256":
+			score = 4
+		case "SHA-256-SESS":
+			score = 3
+		case "", "MD5":
+			score = 2
+		case "MD5-SESS":
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = chal, score
+		}
+	}
+	return best
+}
+
+// parseAuthParams parses a comma separated list of key=value or
+// key="quoted value" pairs.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			val = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = val
+	}
+}
+
+func drainBody(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, 64<<10))
+	body.Close()
+}
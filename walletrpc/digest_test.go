@@ -0,0 +1,171 @@
+package walletrpc
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type digestTestServer struct {
+	mu        sync.Mutex
+	nonce     string
+	seen      map[string]bool
+	challenge int
+}
+
+func (ds *digestTestServer) md5hex(s string) string {
+	h := md5.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func (ds *digestTestServer) check(r *http.Request) (ok, stale bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		return false, false
+	}
+	p := parseAuthParams(auth[7:])
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if p["nonce"] != ds.nonce {
+		return false, true
+	}
+	if ds.seen[p["nc"]] {
+		// replayed nonce count
+		return false, false
+	}
+	ds.seen[p["nc"]] = true
+	ha1 := ds.md5hex("john:monero-rpc:doe")
+	ha2 := ds.md5hex(r.Method + ":" + p["uri"])
+	expected := ds.md5hex(ha1 + ":" + p["nonce"] + ":" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+	return p["response"] == expected, false
+}
+
+func (ds *digestTestServer) rotate() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.nonce = fmt.Sprintf("nonce%d", ds.challenge)
+	ds.seen = make(map[string]bool)
+}
+
+func (ds *digestTestServer) challenges() int {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.challenge
+}
+
+func (ds *digestTestServer) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, stale := ds.check(r)
+		if !ok {
+			ds.mu.Lock()
+			ds.challenge++
+			nonce := ds.nonce
+			ds.mu.Unlock()
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=MD5-sess,realm="monero-rpc",nonce="%v",stale=%v`, nonce, stale))
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=MD5,realm="monero-rpc",nonce="%v",stale=%v`, nonce, stale))
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestDigestAuth(t *testing.T) {
+	ds := &digestTestServer{}
+	ds.rotate()
+	sv0 := httptest.NewServer(ds.wrap(basicTestHandler([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			writerpcResponseOK(H{"height": 10}, w)
+			return true
+		},
+	})))
+	defer sv0.Close()
+
+	rpccl := New(Config{
+		Address:  sv0.URL + "/json_rpc",
+		Username: "john",
+		Password: "doe",
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			height, err := rpccl.GetHeight()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(10), height)
+		}()
+	}
+	wg.Wait()
+	challenges := ds.challenges()
+
+	// the cached nonce is reused
+	_, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, challenges, ds.challenges())
+
+	// a stale nonce triggers a new challenge
+	ds.rotate()
+	_, err = rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, challenges+1, ds.challenges())
+
+	// wrong credentials
+	rpccl = New(Config{
+		Address:  sv0.URL + "/json_rpc",
+		Username: "john",
+		Password: "wrong",
+	})
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+}
+
+func TestParseDigestChallenges(t *testing.T) {
+	chal := parseDigestChallenges([]string{
+		`Basic realm="x"`,
+		`Digest realm="monero-rpc", qop="auth,auth-int", algorithm=MD5, nonce="abc", opaque="o\"p"`,
+		`Digest realm="monero-rpc", qop="auth", algorithm=SHA-256, nonce="def", stale=true`,
+	})
+	if assert.NotNil(t, chal) {
+		assert.Equal(t, "SHA-256", chal.algorithm)
+		assert.Equal(t, "def", chal.nonce)
+		assert.Equal(t, "auth", chal.qop)
+		assert.True(t, chal.stale)
+	}
+	p := parseAuthParams(`realm="a, b", opaque="o\"p", nc=00000001`)
+	assert.Equal(t, "a, b", p["realm"])
+	assert.Equal(t, `o"p`, p["opaque"])
+	assert.Equal(t, "00000001", p["nc"])
+}
+
+func TestDigestQuoting(t *testing.T) {
+	q, err := quoteString(`a "b" \c é`)
+	assert.NoError(t, err)
+	assert.Equal(t, `"a \"b\" \\c é"`, q)
+	_, err = quoteString("a\nb")
+	assert.Error(t, err)
+
+	tr := newDigestTransport("jöhn", "doe", nil)
+	req, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1/json_rpc", nil)
+	chal := &digestChallenge{realm: `wallet "é" \ rpc`, nonce: `n"1`, qop: "auth"}
+	auth, err := tr.authorization(req, chal, 1)
+	if assert.NoError(t, err) {
+		p := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+		assert.Equal(t, "jöhn", p["username"])
+		assert.Equal(t, chal.realm, p["realm"])
+		assert.Equal(t, chal.nonce, p["nonce"])
+	}
+	chal.realm = "wallet\x00rpc"
+	_, err = tr.authorization(req, chal, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+package walletrpc
+
+import (
+	"context"
+)
+
+// Invoker performs a monero-wallet-rpc call. result is a pointer that
+// receives the decoded response, or nil when the method returns nothing.
+type Invoker func(ctx context.Context, method string, params, result interface{}) error
+
+// Interceptor runs around every call made by the client, including the calls
+// of a Batch. It can inspect or modify params before calling next, inspect
+// the decoded result or the error returned by next, or short-circuit the call
+// by returning without calling next at all.
+//
+// The duration of the call is not passed to the interceptor: time next
+// instead. It includes the wait in the Queue and every retry, and for the
+// calls of a Batch, the whole batch.
+type Interceptor func(ctx context.Context, method string, params, result interface{}, next Invoker) error
+
+// ChainInterceptors composes several interceptors into a single one. The
+// first interceptor is the outermost.
+func ChainInterceptors(interceptors ...Interceptor) Interceptor {
+	return func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+		return chainInvoker(interceptors, next)(ctx, method, params, result)
+	}
+}
+
+func chainInvoker(interceptors []Interceptor, final Invoker) Invoker {
+	invoke := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic, next := interceptors[i], invoke
+		if ic == nil {
+			continue
+		}
+		invoke = func(ctx context.Context, method string, params, result interface{}) error {
+			return ic(ctx, method, params, result, next)
+		}
+	}
+	return invoke
+}
@@ -0,0 +1,81 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptors(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method == "get_tx_notes" {
+				var jin struct {
+					TxIDs []string `json:"txids"`
+				}
+				json.Unmarshal(*params, &jin)
+				writerpcResponseOK(H{"notes": jin.TxIDs}, w)
+				return true
+			}
+			return false
+		},
+	})
+	defer sv0.Close()
+
+	var order []string
+	var seenResult interface{}
+	var took time.Duration
+	errDenied := errors.New("denied by policy")
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Interceptors: []Interceptor{
+			func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+				order = append(order, "outer:"+method)
+				start := time.Now()
+				err := next(ctx, method, params, result)
+				took = time.Since(start)
+				seenResult = result
+				return err
+			},
+			func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+				order = append(order, "inner:"+method)
+				if method == "transfer" {
+					return errDenied
+				}
+				return next(ctx, method, params, result)
+			},
+		},
+	})
+
+	notes, err := rpccl.GetTxNotes([]string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, notes)
+	assert.Equal(t, []string{"outer:get_tx_notes", "inner:get_tx_notes"}, order)
+	assert.NotNil(t, seenResult)
+	assert.True(t, took > 0)
+
+	_, err = rpccl.Transfer(TransferRequest{})
+	assert.Equal(t, errDenied, err)
+}
+
+func TestChainInterceptors(t *testing.T) {
+	var order []int
+	mk := func(n int) Interceptor {
+		return func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+			order = append(order, n)
+			return next(ctx, method, params, result)
+		}
+	}
+	ic := ChainInterceptors(mk(1), nil, mk(2))
+	err := ic(context.Background(), "getheight", nil, nil, func(ctx context.Context, method string, params, result interface{}) error {
+		order = append(order, 0)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 0}, order)
+}
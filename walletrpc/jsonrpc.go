@@ -0,0 +1,163 @@
+package walletrpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gorilla/rpc/v2/json2"
+)
+
+// DefaultMaxResponseSize is the largest response body accepted when
+// Config.MaxResponseSize is zero.
+const DefaultMaxResponseSize = 128 << 20
+
+var (
+	// ErrResponseTooLarge is returned when a response body exceeds the
+	// maximum response size.
+	ErrResponseTooLarge = errors.New("walletrpc: response too large")
+	// ErrInvalidResponse is returned when a response is not a valid
+	// JSON-RPC 2.0 response to the request that was sent.
+	ErrInvalidResponse = errors.New("walletrpc: invalid JSON-RPC response")
+)
+
+// UnknownFieldError is returned in strict decoding mode when a response has
+// a field that the result type does not know about.
+type UnknownFieldError struct {
+	Method string
+	Field  string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("walletrpc: %v: unknown field %q in response", e.Method, e.Field)
+}
+
+// rpcRequest is a JSON-RPC 2.0 request sent by the client.
+type rpcRequest struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+	ID      uint64      `json:"id"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response returned by monero-wallet-rpc.
+type rpcResponse struct {
+	Version string           `json:"jsonrpc"`
+	ID      json.RawMessage  `json:"id"`
+	Result  *json.RawMessage `json:"result"`
+	Error   *json.RawMessage `json:"error"`
+}
+
+var lastRPCID uint64
+
+func init() {
+	var buf [8]byte
+	rand.Read(buf[:])
+	// keep some headroom so the counter never wraps
+	lastRPCID = binary.LittleEndian.Uint64(buf[:]) >> 12
+}
+
+// nextRPCID returns a unique JSON-RPC request id.
+func nextRPCID() uint64 {
+	return atomic.AddUint64(&lastRPCID, 1)
+}
+
+func newRPCRequest(method string, params interface{}) *rpcRequest {
+	return &rpcRequest{
+		Version: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      nextRPCID(),
+	}
+}
+
+// id returns the numeric id of the response, or false if it is missing or
+// not a number.
+func (r *rpcResponse) id() (uint64, bool) {
+	id, err := strconv.ParseUint(strings.Trim(string(r.ID), `"`), 10, 64)
+	return id, err == nil
+}
+
+// validate checks that r is a JSON-RPC 2.0 response to the request id.
+func (r *rpcResponse) validate(id uint64) error {
+	if r.Version != "2.0" {
+		return fmt.Errorf("%w: unexpected jsonrpc version %q", ErrInvalidResponse, r.Version)
+	}
+	if rid, ok := r.id(); !ok || rid != id {
+		if r.Error != nil && (len(r.ID) == 0 || string(r.ID) == "null") {
+			// the server could not read the request id
+			return nil
+		}
+		return fmt.Errorf("%w: response id %s does not match request id %v", ErrInvalidResponse, r.ID, id)
+	}
+	if r.Result == nil && r.Error == nil {
+		return fmt.Errorf("%w: neither result nor error is set", ErrInvalidResponse)
+	}
+	return nil
+}
+
+// decodeResult decodes the result of a response into out, or returns its
+// error as a *WalletError. A nil out discards the result. In strict mode,
+// unknown result fields are reported with an UnknownFieldError.
+func (r *rpcResponse) decodeResult(method string, out interface{}, strict bool) error {
+	if r.Error != nil {
+		werr := &WalletError{}
+		if err := json.Unmarshal(*r.Error, werr); err != nil {
+			return &WalletError{
+				Code:    ErrorCode(json2.E_SERVER),
+				Message: string(*r.Error),
+			}
+		}
+		return werr
+	}
+	if r.Result == nil {
+		return json2.ErrNullResult
+	}
+	if out == nil {
+		// the caller discards the result
+		return nil
+	}
+	if !strict {
+		return json.Unmarshal(*r.Result, out)
+	}
+	dec := json.NewDecoder(bytes.NewReader(*r.Result))
+	dec.DisallowUnknownFields()
+	return asUnknownFieldError(method, dec.Decode(out))
+}
+
+// asUnknownFieldError converts the error of a json.Decoder with
+// DisallowUnknownFields into an UnknownFieldError.
+func asUnknownFieldError(method string, err error) error {
+	const prefix = "json: unknown field "
+	if err == nil || !strings.HasPrefix(err.Error(), prefix) {
+		return err
+	}
+	field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), prefix))
+	return &UnknownFieldError{
+		Method: method,
+		Field:  field,
+	}
+}
+
+// readBody reads at most max bytes of body. A negative max means no limit.
+func readBody(body io.Reader, max int64) ([]byte, error) {
+	if max < 0 {
+		return ioutil.ReadAll(body)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > max {
+		return nil, ErrResponseTooLarge
+	}
+	return buf, nil
+}
@@ -0,0 +1,137 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseValidation(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "getheight":
+				w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"height":1}}`))
+			case "getaddress":
+				w.Write([]byte(`{"jsonrpc":"1.0","id":` + idOf(w) + `,"result":{"address":"x"}}`))
+			case "getbalance":
+				w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`))
+			case "get_languages":
+				writerpcResponseOK(H{"languages": []string{strings.Repeat("a", 1024)}}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := New(Config{
+		Address:         sv0.URL + "/json_rpc",
+		MaxResponseSize: 512,
+	})
+	_, err := rpccl.GetHeight()
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "%v", err)
+	_, err = rpccl.GetAddress()
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "%v", err)
+	_, _, err = rpccl.GetBalance()
+	iswerr, werr := GetWalletError(err)
+	assert.True(t, iswerr)
+	assert.Equal(t, ErrorClassCaller, werr.Code.Class())
+	_, err = rpccl.GetLanguages()
+	assert.Equal(t, ErrResponseTooLarge, err)
+}
+
+func TestStrictDecoding(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method == "getbalance" {
+				writerpcResponseOK(H{"balance": 1, "unlocked_balance": 1, "multisig_import_needed": false}, w)
+				return true
+			}
+			return false
+		},
+	})
+	defer sv0.Close()
+
+	_, _, err := New(Config{
+		Address: sv0.URL + "/json_rpc",
+	}).GetBalance()
+	assert.NoError(t, err)
+
+	_, _, err = New(Config{
+		Address:        sv0.URL + "/json_rpc",
+		StrictDecoding: true,
+	}).GetBalance()
+	var ferr *UnknownFieldError
+	if assert.True(t, errors.As(err, &ferr), "%v", err) {
+		assert.Equal(t, "getbalance", ferr.Method)
+		assert.Equal(t, "multisig_import_needed", ferr.Field)
+	}
+}
+
+func TestStrictDecodingNilResult(t *testing.T) {
+	sv0 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		type request struct {
+			ID     uint64 `json:"id"`
+			Method string `json:"method"`
+		}
+		resp := func(req request) H {
+			assert.Equal(t, "relay_tx", req.Method)
+			return H{"jsonrpc": "2.0", "id": req.ID, "result": H{"tx_hash": "abc"}}
+		}
+		var reqs []request
+		if json.Unmarshal(body, &reqs) == nil {
+			resps := make([]H, 0, len(reqs))
+			for _, req := range reqs {
+				resps = append(resps, resp(req))
+			}
+			json.NewEncoder(w).Encode(resps)
+			return
+		}
+		var req request
+		assert.NoError(t, json.Unmarshal(body, &req))
+		json.NewEncoder(w).Encode(resp(req))
+	}))
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address:        sv0.URL + "/json_rpc",
+		StrictDecoding: true,
+	})
+	params := H{"hex": "00"}
+	assert.NoError(t, rpccl.Call("relay_tx", params, nil))
+
+	b := rpccl.NewBatch()
+	c0 := b.Add("relay_tx", params, nil)
+	c1 := b.Add("relay_tx", params, nil)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.NoError(t, c1.Error)
+
+	// a result that is decoded is still checked
+	var out struct{}
+	err := rpccl.Call("relay_tx", params, &out)
+	var ferr *UnknownFieldError
+	if assert.True(t, errors.As(err, &ferr), "%v", err) {
+		assert.Equal(t, "tx_hash", ferr.Field)
+	}
+}
+
+func TestRPCIDs(t *testing.T) {
+	a := newRPCRequest("getheight", nil)
+	b := newRPCRequest("getheight", nil)
+	assert.NotEqual(t, a.ID, b.ID)
+}
+
+func idOf(w http.ResponseWriter) string {
+	buf, _ := json.Marshal(w.(*rpcTestWriter).id)
+	return string(buf)
+}
@@ -0,0 +1,160 @@
+package walletrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Redacted replaces the sensitive values in logged calls.
+const Redacted = "[REDACTED]"
+
+// LogEntry is the structured record of a single call.
+type LogEntry struct {
+	Method string
+	// Params and Result are generic JSON values (maps, slices, strings...)
+	// with the sensitive fields replaced by Redacted.
+	Params   interface{}
+	Result   interface{}
+	Duration time.Duration
+	Err      error
+	// ErrorCode is set when Err is a monero-wallet-rpc error.
+	ErrorCode ErrorCode
+}
+
+// Logger receives a LogEntry after every call made by the client.
+type Logger interface {
+	LogCall(entry *LogEntry)
+}
+
+// LoggerFunc is an adapter to use a function as a Logger.
+type LoggerFunc func(entry *LogEntry)
+
+// LogCall calls f(entry).
+func (f LoggerFunc) LogCall(entry *LogEntry) {
+	f(entry)
+}
+
+// sensitiveFields lists, per method, the top level params and result fields
+// that must never be logged, besides the alwaysSensitive ones.
+var sensitiveFields = map[string]struct {
+	params []string
+	result []string
+}{
+	"export_key_images": {result: []string{"signed_key_images"}},
+	"import_key_images": {params: []string{"signed_key_images"}},
+}
+
+// alwaysSensitive fields are redacted at any depth, whatever the method, so
+// they stay hidden in Call and CallRaw and in methods added later.
+var alwaysSensitive = map[string]bool{
+	"password":      true,
+	"old_password":  true,
+	"new_password":  true,
+	"mnemonic":      true,
+	"seed":          true,
+	"seed_offset":   true,
+	"key":           true,
+	"spendkey":      true,
+	"viewkey":       true,
+	"spend_key":     true,
+	"view_key":      true,
+	"tx_key":        true,
+	"tx_key_list":   true,
+	"multisig_info": true,
+}
+
+// redactParams returns a generic copy of the params of a call with the
+// sensitive fields redacted.
+func redactParams(method string, params interface{}) interface{} {
+	return redactValue(params, sensitiveFields[method].params)
+}
+
+// redactResult returns a generic copy of the result of a call with the
+// sensitive fields redacted.
+func redactResult(method string, result interface{}) interface{} {
+	return redactValue(result, sensitiveFields[method].result)
+}
+
+func redactValue(v interface{}, fields []string) interface{} {
+	if v == nil {
+		return nil
+	}
+	var generic interface{}
+	switch vv := v.(type) {
+	case json.RawMessage:
+		if err := unmarshalGeneric(vv, &generic); err != nil {
+			return Redacted
+		}
+	case *json.RawMessage:
+		if vv == nil {
+			return nil
+		}
+		if err := unmarshalGeneric(*vv, &generic); err != nil {
+			return Redacted
+		}
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return Redacted
+		}
+		if err := unmarshalGeneric(buf, &generic); err != nil {
+			return Redacted
+		}
+	}
+	if m, ok := generic.(map[string]interface{}); ok {
+		for _, f := range fields {
+			if _, ok := m[f]; ok {
+				m[f] = Redacted
+			}
+		}
+	}
+	return redactGeneric(generic)
+}
+
+// unmarshalGeneric decodes JSON keeping numbers as json.Number, so amounts
+// above 2^53 keep their precision.
+func unmarshalGeneric(buf []byte, v *interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func redactGeneric(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for k, x := range vv {
+			if alwaysSensitive[k] {
+				vv[k] = Redacted
+				continue
+			}
+			vv[k] = redactGeneric(x)
+		}
+	case []interface{}:
+		for i, x := range vv {
+			vv[i] = redactGeneric(x)
+		}
+	}
+	return v
+}
+
+func newLoggingInterceptor(l Logger) Interceptor {
+	return func(ctx context.Context, method string, params, result interface{}, next Invoker) error {
+		start := time.Now()
+		err := next(ctx, method, params, result)
+		entry := &LogEntry{
+			Method:   method,
+			Params:   redactParams(method, params),
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if err == nil {
+			entry.Result = redactResult(method, result)
+		} else if iswerr, werr := GetWalletError(err); iswerr {
+			entry.ErrorCode = werr.Code
+		}
+		l.LogCall(entry)
+		return err
+	}
+}
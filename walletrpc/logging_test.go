@@ -0,0 +1,122 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerRedaction(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "open_wallet":
+				writerpcResponseOK(H{}, w)
+			case "query_key":
+				writerpcResponseOK(H{"key": "secret seed words"}, w)
+			case "transfer":
+				writerpcResponseOK(H{"fee": 10, "tx_hash": "abc", "tx_key": "secret tx key"}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	var entries []*LogEntry
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Logger: LoggerFunc(func(entry *LogEntry) {
+			entries = append(entries, entry)
+		}),
+	})
+	assert.NoError(t, rpccl.OpenWallet("wallet", "hunter2"))
+	_, err := rpccl.QueryKey(QueryKeyMnemonic)
+	assert.NoError(t, err)
+	_, err = rpccl.Transfer(TransferRequest{GetTxKey: true})
+	assert.NoError(t, err)
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+	assert.Equal(t, "open_wallet", entries[0].Method)
+	assert.Equal(t, map[string]interface{}{"filename": "wallet", "password": Redacted}, entries[0].Params)
+	assert.Equal(t, map[string]interface{}{"key": Redacted}, entries[1].Result)
+	assert.Equal(t, map[string]interface{}{"key_type": "mnemonic"}, entries[1].Params)
+	res := entries[2].Result.(map[string]interface{})
+	assert.Equal(t, Redacted, res["tx_key"])
+	assert.Equal(t, "abc", res["tx_hash"])
+	assert.Equal(t, ErrUnknown, entries[3].ErrorCode)
+	assert.Error(t, entries[3].Err)
+	assert.Nil(t, entries[3].Result)
+
+	for _, e := range entries {
+		buf, _ := json.Marshal(e)
+		assert.NotContains(t, string(buf), "secret")
+		assert.NotContains(t, string(buf), "hunter2")
+	}
+}
+
+func TestRedactGeneric(t *testing.T) {
+	v := redactParams("restore_deterministic_wallet", H{
+		"filename": "w",
+		"seed":     "secret",
+		"nested":   []interface{}{H{"password": "secret"}},
+	})
+	buf, _ := json.Marshal(v)
+	assert.NotContains(t, string(buf), "secret")
+	assert.Contains(t, string(buf), `"filename":"w"`)
+}
+
+func TestLoggerRedactionCallRaw(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "get_tx_key":
+				writerpcResponseOK(H{"tx_key": "secret tx key"}, w)
+			case "check_tx_key":
+				writerpcResponseOK(H{"confirmations": 1, "in_pool": false, "received": 1000}, w)
+			case "prepare_multisig":
+				writerpcResponseOK(H{"multisig_info": "secret multisig info"}, w)
+			case "restore_deterministic_wallet":
+				writerpcResponseOK(H{"address": "addr", "seed": "secret seed"}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	var entries []*LogEntry
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Logger: LoggerFunc(func(entry *LogEntry) {
+			entries = append(entries, entry)
+		}),
+	})
+	raw, err := rpccl.CallRaw("get_tx_key", H{"txid": "abc"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(raw), "secret tx key")
+	_, err = rpccl.CallRaw("check_tx_key", H{"txid": "abc", "tx_key": "secret tx key", "address": "addr"})
+	assert.NoError(t, err)
+	_, err = rpccl.CallRaw("prepare_multisig", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, rpccl.Call("restore_deterministic_wallet", H{"seed": "secret", "seed_offset": "secret"}, nil))
+
+	if !assert.Len(t, entries, 4) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{"tx_key": Redacted}, entries[0].Result)
+	assert.Equal(t, map[string]interface{}{"txid": "abc", "tx_key": Redacted, "address": "addr"}, entries[1].Params)
+	assert.Equal(t, map[string]interface{}{"multisig_info": Redacted}, entries[2].Result)
+	for _, e := range entries {
+		buf, _ := json.Marshal(e)
+		assert.NotContains(t, string(buf), "secret")
+	}
+}
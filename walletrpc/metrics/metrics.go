@@ -0,0 +1,181 @@
+// Package metrics records call counts, latencies and errors of walletrpc
+// clients and exposes them in the Prometheus text format.
+//
+//	m := metrics.New()
+//	client := walletrpc.New(walletrpc.Config{
+//		Address:      "http://127.0.0.1:18082/json_rpc",
+//		Interceptors: []walletrpc.Interceptor{m.Interceptor()},
+//	})
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+// DefaultBuckets are the upper bounds (in seconds) of the latency histogram.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Collector records per-method metrics of walletrpc calls. It implements
+// http.Handler to serve them in the Prometheus text exposition format.
+type Collector struct {
+	buckets []float64
+
+	mu      sync.Mutex
+	methods map[string]*methodStats
+}
+
+type methodStats struct {
+	count           uint64
+	sum             float64
+	buckets         []uint64
+	walletErrors    map[walletrpc.ErrorCode]uint64
+	transportErrors uint64
+}
+
+// New returns a Collector that uses DefaultBuckets.
+func New() *Collector {
+	return NewWithBuckets(DefaultBuckets)
+}
+
+// NewWithBuckets returns a Collector with custom latency buckets (in
+// seconds). The +Inf bucket is always exported, so it does not need to be
+// listed; duplicate and NaN bounds are dropped.
+func NewWithBuckets(buckets []float64) *Collector {
+	b := make([]float64, 0, len(buckets))
+	for _, le := range buckets {
+		if !math.IsInf(le, 1) && !math.IsNaN(le) {
+			b = append(b, le)
+		}
+	}
+	sort.Float64s(b)
+	for i := len(b) - 1; i > 0; i-- {
+		if b[i] == b[i-1] {
+			b = append(b[:i], b[i+1:]...)
+		}
+	}
+	return &Collector{
+		buckets: b,
+		methods: make(map[string]*methodStats),
+	}
+}
+
+// Interceptor returns a walletrpc.Interceptor that records every call.
+func (c *Collector) Interceptor() walletrpc.Interceptor {
+	return func(ctx context.Context, method string, params, result interface{}, next walletrpc.Invoker) error {
+		start := time.Now()
+		err := next(ctx, method, params, result)
+		c.Observe(method, time.Since(start), err)
+		return err
+	}
+}
+
+// Observe records a single call.
+func (c *Collector) Observe(method string, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ms := c.methods[method]
+	if ms == nil {
+		ms = &methodStats{
+			buckets:      make([]uint64, len(c.buckets)),
+			walletErrors: make(map[walletrpc.ErrorCode]uint64),
+		}
+		c.methods[method] = ms
+	}
+	secs := d.Seconds()
+	ms.count++
+	ms.sum += secs
+	for i, le := range c.buckets {
+		if secs <= le {
+			ms.buckets[i]++
+		}
+	}
+	if err == nil {
+		return
+	}
+	if iswerr, werr := walletrpc.GetWalletError(err); iswerr {
+		ms.walletErrors[werr.Code]++
+		return
+	}
+	ms.transportErrors++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteText(w)
+}
+
+// WriteText writes the metrics in the Prometheus text format.
+func (c *Collector) WriteText(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.methods))
+	for k := range c.methods {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP walletrpc_requests_total Total number of monero-wallet-rpc calls.")
+	fmt.Fprintln(w, "# TYPE walletrpc_requests_total counter")
+	for _, name := range names {
+		fmt.Fprintf(w, "walletrpc_requests_total{method=\"%v\"} %v\n", escape(name), c.methods[name].count)
+	}
+
+	fmt.Fprintln(w, "# HELP walletrpc_request_duration_seconds Latency of monero-wallet-rpc calls.")
+	fmt.Fprintln(w, "# TYPE walletrpc_request_duration_seconds histogram")
+	for _, name := range names {
+		ms := c.methods[name]
+		for i, le := range c.buckets {
+			fmt.Fprintf(w, "walletrpc_request_duration_seconds_bucket{method=\"%v\",le=\"%v\"} %v\n", escape(name), formatFloat(le), ms.buckets[i])
+		}
+		fmt.Fprintf(w, "walletrpc_request_duration_seconds_bucket{method=\"%v\",le=\"+Inf\"} %v\n", escape(name), ms.count)
+		fmt.Fprintf(w, "walletrpc_request_duration_seconds_sum{method=\"%v\"} %v\n", escape(name), formatFloat(ms.sum))
+		fmt.Fprintf(w, "walletrpc_request_duration_seconds_count{method=\"%v\"} %v\n", escape(name), ms.count)
+	}
+
+	fmt.Fprintln(w, "# HELP walletrpc_errors_total Failed monero-wallet-rpc calls by kind (wallet or transport), wallet error code and class.")
+	fmt.Fprintln(w, "# TYPE walletrpc_errors_total counter")
+	for _, name := range names {
+		ms := c.methods[name]
+		codes := make([]int, 0, len(ms.walletErrors))
+		for code := range ms.walletErrors {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			ecode := walletrpc.ErrorCode(code)
+			fmt.Fprintf(w, "walletrpc_errors_total{method=\"%v\",kind=\"wallet\",code=\"%v\",class=\"%v\"} %v\n", escape(name), code, ecode.Class(), ms.walletErrors[ecode])
+		}
+		if ms.transportErrors > 0 {
+			fmt.Fprintf(w, "walletrpc_errors_total{method=\"%v\",kind=\"transport\",code=\"\",class=\"\"} %v\n", escape(name), ms.transportErrors)
+		}
+	}
+	return w.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	m := NewWithBuckets([]float64{0.1, 1})
+	m.Observe("getbalance", 50*time.Millisecond, nil)
+	m.Observe("getbalance", 500*time.Millisecond, errors.New("connection reset"))
+	m.Observe("transfer", 2*time.Second, &json2.Error{Code: json2.ErrorCode(walletrpc.ErrGenericTransferError), Message: "not enough money"})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	out := string(body)
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, out, "# TYPE walletrpc_requests_total counter\n")
+	assert.Contains(t, out, `walletrpc_requests_total{method="getbalance"} 2`+"\n")
+	assert.Contains(t, out, `walletrpc_request_duration_seconds_bucket{method="getbalance",le="0.1"} 1`+"\n")
+	assert.Contains(t, out, `walletrpc_request_duration_seconds_bucket{method="getbalance",le="1"} 2`+"\n")
+	assert.Contains(t, out, `walletrpc_request_duration_seconds_bucket{method="transfer",le="1"} 0`+"\n")
+	assert.Contains(t, out, `walletrpc_request_duration_seconds_bucket{method="transfer",le="+Inf"} 1`+"\n")
+	assert.Contains(t, out, `walletrpc_request_duration_seconds_sum{method="getbalance"} 0.55`+"\n")
+	assert.Contains(t, out, `walletrpc_errors_total{method="getbalance",kind="transport",code="",class=""} 1`+"\n")
+	assert.Contains(t, out, `walletrpc_errors_total{method="transfer",kind="wallet",code="-4",class="fatal"} 1`+"\n")
+}
+
+func TestCollectorInfBucket(t *testing.T) {
+	m := NewWithBuckets([]float64{1, math.Inf(1), 0.1, 1})
+	m.Observe("getbalance", 50*time.Millisecond, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	out := string(body)
+
+	assert.Equal(t, 1, strings.Count(out, `walletrpc_request_duration_seconds_bucket{method="getbalance",le="+Inf"}`))
+	assert.Equal(t, 1, strings.Count(out, `walletrpc_request_duration_seconds_bucket{method="getbalance",le="1"}`))
+	assert.Equal(t, 3, strings.Count(out, `walletrpc_request_duration_seconds_bucket{`))
+}
@@ -0,0 +1,336 @@
+package walletrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoEndpoints is returned by a Pool without endpoints.
+	ErrNoEndpoints = errors.New("walletrpc: pool has no endpoints")
+	// ErrWalletSelection is returned by a Pool for the calls that open,
+	// create or close a wallet. Sent to a single endpoint, they would
+	// leave the endpoints serving different wallets.
+	ErrWalletSelection = errors.New("walletrpc: a pool cannot open, create or close wallets")
+)
+
+// walletSelectionMethods change the wallet served by an endpoint.
+var walletSelectionMethods = map[string]bool{
+	"open_wallet":                  true,
+	"create_wallet":                true,
+	"close_wallet":                 true,
+	"restore_deterministic_wallet": true,
+	"generate_from_keys":           true,
+}
+
+// PoolConfig holds the configuration of a Pool.
+type PoolConfig struct {
+	// Endpoints are the replicated monero-wallet-rpc instances. The first
+	// healthy endpoint becomes the primary.
+	Endpoints []Config
+	// HealthCheckInterval is the time between GetHeight health checks.
+	// Defaults to 10s.
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout defaults to 5s.
+	HealthCheckTimeout time.Duration
+	// MaxFailures is the number of consecutive failed calls or health
+	// checks after which an endpoint is ejected. Defaults to 3.
+	MaxFailures int
+}
+
+// EndpointStatus is a snapshot of the state of a Pool endpoint.
+type EndpointStatus struct {
+	Address string
+	Healthy bool
+	Primary bool
+	Height  uint64
+	Latency time.Duration
+}
+
+// Pool is a Client that spreads calls across several monero-wallet-rpc
+// instances of the same wallet. Read-only calls go to the healthiest and
+// most synced endpoint; every other call goes to a sticky primary, which
+// only changes when it is ejected. Endpoints are ejected after MaxFailures
+// consecutive failures and admitted back after a successful health check.
+//
+// A method is read-only when the RetryPolicy of every endpoint classifies it
+// so, which lets RetryPolicy.Methods route more methods to the replicas.
+//
+// Every endpoint must already serve the wallet, for example by starting
+// monero-wallet-rpc with --wallet-file. A Pool fails the calls that open,
+// create or close a wallet with ErrWalletSelection.
+type Pool struct {
+	*client
+	cfg       PoolConfig
+	endpoints []*poolEndpoint
+
+	mu      sync.Mutex
+	primary int
+
+	done chan struct{}
+	once sync.Once
+}
+
+type poolEndpoint struct {
+	cl       *client
+	addr     string
+	healthy  bool
+	height   uint64
+	latency  time.Duration
+	failures int
+}
+
+// NewPool returns a new Pool and starts health checking its endpoints.
+// Call Close to stop the health checks.
+func NewPool(cfg PoolConfig) *Pool {
+	if cfg.HealthCheckInterval <= 0 {
+		cfg.HealthCheckInterval = 10 * time.Second
+	}
+	if cfg.HealthCheckTimeout <= 0 {
+		cfg.HealthCheckTimeout = 5 * time.Second
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = 3
+	}
+	p := &Pool{
+		cfg:  cfg,
+		done: make(chan struct{}),
+	}
+	for _, ecfg := range cfg.Endpoints {
+		p.endpoints = append(p.endpoints, &poolEndpoint{
+			cl:      newClient(ecfg),
+			addr:    ecfg.Address,
+			healthy: true,
+		})
+	}
+	p.client = &client{
+		invoke: p.route,
+	}
+	go p.healthLoop()
+	return p
+}
+
+// Close stops the health checks.
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	return nil
+}
+
+// NewBatch returns a Batch sent to the primary endpoint. Batches count
+// towards the failures of the endpoint like any other call.
+func (p *Pool) NewBatch() *Batch {
+	return newRoutedBatch(p.routeBatch)
+}
+
+// Status returns the state of every endpoint, in configuration order.
+func (p *Pool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := make([]EndpointStatus, len(p.endpoints))
+	primary := -1
+	if len(p.endpoints) > 0 {
+		primary = p.primaryLocked()
+	}
+	for i, e := range p.endpoints {
+		st[i] = EndpointStatus{
+			Address: e.addr,
+			Healthy: e.healthy,
+			Primary: i == primary,
+			Height:  e.height,
+			Latency: e.latency,
+		}
+	}
+	return st
+}
+
+// route is the Invoker of the pool.
+func (p *Pool) route(ctx context.Context, method string, params, result interface{}) error {
+	if len(p.endpoints) == 0 {
+		return ErrNoEndpoints
+	}
+	if walletSelectionMethods[method] {
+		return fmt.Errorf("%w: %v", ErrWalletSelection, method)
+	}
+	p.mu.Lock()
+	var idx int
+	if p.readOnly(method) {
+		idx = p.bestReaderLocked()
+	} else {
+		idx = p.primaryLocked()
+	}
+	e := p.endpoints[idx]
+	p.mu.Unlock()
+
+	err := e.cl.do(ctx, method, params, result)
+	p.record(ctx, idx, err)
+	return err
+}
+
+// readOnly reports whether every endpoint classifies method as read-only.
+func (p *Pool) readOnly(method string) bool {
+	for _, e := range p.endpoints {
+		if e.cl.retry.classify(method) != MethodReadOnly {
+			return false
+		}
+	}
+	return true
+}
+
+// routeBatch sends the calls of a Batch to the primary endpoint.
+func (p *Pool) routeBatch(ctx context.Context, calls []*BatchCall) error {
+	if len(p.endpoints) == 0 {
+		for _, call := range calls {
+			call.Error = ErrBatchNotSent
+		}
+		return ErrNoEndpoints
+	}
+	for _, call := range calls {
+		if walletSelectionMethods[call.Method] {
+			for _, c := range calls {
+				c.Error = ErrBatchNotSent
+			}
+			return fmt.Errorf("%w: %v", ErrWalletSelection, call.Method)
+		}
+	}
+	p.mu.Lock()
+	idx := p.primaryLocked()
+	e := p.endpoints[idx]
+	p.mu.Unlock()
+
+	err := (&Batch{c: e.cl, calls: calls}).SendContext(ctx)
+	switch {
+	case err == ErrBatchUnsupported:
+		// the endpoint responded
+	case err != nil:
+		p.record(ctx, idx, err)
+	default:
+		// calls sent one at a time fail on their own
+		for _, call := range calls {
+			if iswerr, _ := GetWalletError(call.Error); call.Error != nil && !iswerr {
+				err = call.Error
+				break
+			}
+		}
+		p.record(ctx, idx, err)
+		err = nil
+	}
+	return err
+}
+
+// record counts the failures of an endpoint after a call. Wallet errors
+// are not failures of the endpoint.
+func (p *Pool) record(ctx context.Context, idx int, err error) {
+	if err != nil && ctx.Err() == nil {
+		if iswerr, _ := GetWalletError(err); !iswerr {
+			p.fail(idx)
+		}
+	} else if err == nil {
+		p.mu.Lock()
+		p.endpoints[idx].failures = 0
+		p.mu.Unlock()
+	}
+}
+
+// primaryLocked returns the sticky primary, electing a new one if the
+// current primary was ejected.
+func (p *Pool) primaryLocked() int {
+	if p.endpoints[p.primary].healthy {
+		return p.primary
+	}
+	for i, e := range p.endpoints {
+		if e.healthy {
+			p.primary = i
+			return i
+		}
+	}
+	// everything is down; keep trying the current primary
+	return p.primary
+}
+
+// bestReaderLocked returns the healthy endpoint with the highest height,
+// breaking ties by latency.
+func (p *Pool) bestReaderLocked() int {
+	best := -1
+	for i, e := range p.endpoints {
+		if !e.healthy {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		b := p.endpoints[best]
+		if e.height > b.height || (e.height == b.height && e.latency < b.latency) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return p.primaryLocked()
+	}
+	return best
+}
+
+func (p *Pool) fail(idx int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoints[idx]
+	e.failures++
+	if e.failures >= p.cfg.MaxFailures {
+		e.healthy = false
+	}
+}
+
+func (p *Pool) healthLoop() {
+	p.checkAll()
+	t := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.checkAll()
+		}
+	}
+}
+
+func (p *Pool) checkAll() {
+	var wg sync.WaitGroup
+	for i := range p.endpoints {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			p.check(idx)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) check(idx int) {
+	e := p.endpoints[idx]
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.HealthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	height, err := e.cl.GetHeightContext(ctx)
+	latency := time.Since(start)
+	if err != nil {
+		p.fail(idx)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.height = height
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		// exponentially weighted moving average
+		e.latency = (e.latency*3 + latency) / 4
+	}
+	e.failures = 0
+	e.healthy = true
+}
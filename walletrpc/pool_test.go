@@ -0,0 +1,209 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ ClientContext = (*Pool)(nil)
+
+type poolTestServer struct {
+	*httptest.Server
+	height uint64
+	down   int32
+	calls  map[string]*int32
+}
+
+func newPoolTestServer(height uint64) *poolTestServer {
+	ps := &poolTestServer{
+		height: height,
+		calls: map[string]*int32{
+			"getbalance":  new(int32),
+			"store":       new(int32),
+			"open_wallet": new(int32),
+		},
+	}
+	ps.Server = basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if atomic.LoadInt32(&ps.down) == 1 {
+				http.Error(w, "down", http.StatusBadGateway)
+				return true
+			}
+			if n, ok := ps.calls[method]; ok {
+				atomic.AddInt32(n, 1)
+			}
+			switch method {
+			case "getheight":
+				writerpcResponseOK(H{"height": ps.height}, w)
+			case "getbalance":
+				writerpcResponseOK(H{"balance": 1, "unlocked_balance": 1}, w)
+			case "store":
+				writerpcResponseOK(H{}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	return ps
+}
+
+func (ps *poolTestServer) count(method string) int32 {
+	return atomic.LoadInt32(ps.calls[method])
+}
+
+func TestPool(t *testing.T) {
+	sv0 := newPoolTestServer(100)
+	defer sv0.Close()
+	sv1 := newPoolTestServer(102)
+	defer sv1.Close()
+	sv2 := newPoolTestServer(101)
+	defer sv2.Close()
+
+	pool := NewPool(PoolConfig{
+		Endpoints: []Config{
+			{Address: sv0.URL + "/json_rpc"},
+			{Address: sv1.URL + "/json_rpc"},
+			{Address: sv2.URL + "/json_rpc"},
+		},
+		HealthCheckInterval: 20 * time.Millisecond,
+		MaxFailures:         2,
+	})
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Status()[1].Height == 102 })
+
+	// reads go to the most synced endpoint
+	_, _, err := pool.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), sv1.count("getbalance"))
+
+	// writes go to the primary
+	assert.NoError(t, pool.Store())
+	assert.Equal(t, int32(1), sv0.count("store"))
+	assert.True(t, pool.Status()[0].Primary)
+
+	// the primary goes down and is ejected
+	atomic.StoreInt32(&sv0.down, 1)
+	waitFor(t, func() bool { return !pool.Status()[0].Healthy })
+	assert.NoError(t, pool.Store())
+	assert.Equal(t, int32(1), sv1.count("store"))
+
+	// it comes back, but the new primary is sticky
+	atomic.StoreInt32(&sv0.down, 0)
+	waitFor(t, func() bool { return pool.Status()[0].Healthy })
+	assert.NoError(t, pool.Store())
+	assert.Equal(t, int32(2), sv1.count("store"))
+	assert.Equal(t, int32(1), sv0.count("store"))
+}
+
+func TestPoolRouting(t *testing.T) {
+	sv0 := newPoolTestServer(100)
+	defer sv0.Close()
+	sv1 := newPoolTestServer(102)
+	defer sv1.Close()
+
+	readOnlyStore := &RetryPolicy{Methods: map[string]MethodClass{"store": MethodReadOnly}}
+	pool := NewPool(PoolConfig{
+		Endpoints: []Config{
+			{Address: sv0.URL + "/json_rpc", Retry: readOnlyStore},
+			{Address: sv1.URL + "/json_rpc", Retry: readOnlyStore},
+		},
+		HealthCheckInterval: time.Hour,
+	})
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Status()[1].Height == 102 })
+
+	// the endpoints classify store as read-only
+	assert.NoError(t, pool.Store())
+	assert.Equal(t, int32(0), sv0.count("store"))
+	assert.Equal(t, int32(1), sv1.count("store"))
+
+	// but not all of them
+	pool = NewPool(PoolConfig{
+		Endpoints: []Config{
+			{Address: sv0.URL + "/json_rpc"},
+			{Address: sv1.URL + "/json_rpc", Retry: readOnlyStore},
+		},
+		HealthCheckInterval: time.Hour,
+	})
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Status()[1].Height == 102 })
+	assert.NoError(t, pool.Store())
+	assert.Equal(t, int32(1), sv0.count("store"))
+
+	// wallets are not opened on a single endpoint
+	err := pool.OpenWallet("wallet", "pass")
+	assert.True(t, errors.Is(err, ErrWalletSelection), "%v", err)
+	b := pool.NewBatch()
+	c0 := b.Add("close_wallet", nil, nil)
+	err = b.Send()
+	assert.True(t, errors.Is(err, ErrWalletSelection), "%v", err)
+	assert.Equal(t, ErrBatchNotSent, c0.Error)
+	assert.Equal(t, int32(0), sv0.count("open_wallet")+sv1.count("open_wallet"))
+	assert.True(t, pool.Status()[0].Healthy)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolBatch(t *testing.T) {
+	pool := NewPool(PoolConfig{})
+	defer pool.Close()
+	var height uint64
+	b := pool.NewBatch()
+	c0 := b.GetHeight(&height)
+	assert.Equal(t, ErrNoEndpoints, b.Send())
+	assert.Equal(t, ErrBatchNotSent, c0.Error)
+
+	sv0 := newPoolTestServer(100)
+	defer sv0.Close()
+	sv1 := newPoolTestServer(100)
+	defer sv1.Close()
+	pool = NewPool(PoolConfig{
+		Endpoints: []Config{
+			{Address: sv0.URL + "/json_rpc"},
+			{Address: sv1.URL + "/json_rpc"},
+		},
+		HealthCheckInterval: time.Hour,
+		MaxFailures:         2,
+	})
+	defer pool.Close()
+	waitFor(t, func() bool { return pool.Status()[1].Height == 100 })
+
+	b = pool.NewBatch()
+	c0 = b.Add("store", nil, nil)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.Equal(t, int32(1), sv0.count("store"))
+
+	// the primary is ejected by batch traffic alone
+	atomic.StoreInt32(&sv0.down, 1)
+	for i := 0; i < 2; i++ {
+		b = pool.NewBatch()
+		c0 = b.Add("store", nil, nil)
+		assert.NoError(t, b.Send())
+		assert.Error(t, c0.Error)
+	}
+	assert.False(t, pool.Status()[0].Healthy)
+	assert.True(t, pool.Status()[1].Primary)
+
+	b = pool.NewBatch()
+	c0 = b.Add("store", nil, nil)
+	assert.NoError(t, b.Send())
+	assert.NoError(t, c0.Error)
+	assert.Equal(t, int32(1), sv1.count("store"))
+}
@@ -0,0 +1,220 @@
+package walletrpc
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Queue priorities used by default, by MethodClass. Calls with a higher
+// priority are sent first.
+const (
+	QueuePriorityRead  = 0
+	QueuePriorityWrite = 10
+	QueuePrioritySend  = 20
+)
+
+// QueueConfig holds the configuration of a Queue.
+type QueueConfig struct {
+	// MaxInFlight is the number of requests that can be sent to the
+	// endpoint at the same time. Defaults to 1, since monero-wallet-rpc
+	// processes a single request at a time.
+	MaxInFlight int
+	// RateLimit is the maximum number of requests per second. Zero means
+	// no limit.
+	RateLimit float64
+	// Burst is the number of requests that can exceed RateLimit at once.
+	// Defaults to 1.
+	Burst int
+	// Priorities overrides the priority of the given methods.
+	Priorities map[string]int
+}
+
+// Queue orders the requests made to a single monero-wallet-rpc endpoint.
+// Waiting calls are sent by priority (sends before writes before reads),
+// and in arrival order within the same priority. A Queue can be shared by
+// every client that talks to the same endpoint.
+type Queue struct {
+	cfg QueueConfig
+
+	mu       sync.Mutex
+	waiters  waiterHeap
+	inFlight int
+	seq      uint64
+	tokens   float64
+	last     time.Time
+	timer    *time.Timer
+}
+
+// NewQueue returns a new Queue.
+func NewQueue(cfg QueueConfig) *Queue {
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 1
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	return &Queue{
+		cfg:    cfg,
+		tokens: float64(cfg.Burst),
+		last:   time.Now(),
+	}
+}
+
+// Depth returns the number of calls waiting to be sent.
+func (q *Queue) Depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiters)
+}
+
+// InFlight returns the number of calls currently being sent.
+func (q *Queue) InFlight() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inFlight
+}
+
+type queuePriorityKey struct{}
+
+// WithQueuePriority returns a context that overrides the queue priority of
+// the calls made with it.
+func WithQueuePriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, queuePriorityKey{}, priority)
+}
+
+func (q *Queue) priority(ctx context.Context, method string) int {
+	if p, ok := ctx.Value(queuePriorityKey{}).(int); ok {
+		return p
+	}
+	if p, ok := q.cfg.Priorities[method]; ok {
+		return p
+	}
+	switch ClassifyMethod(method) {
+	case MethodReadOnly:
+		return QueuePriorityRead
+	case MethodIdempotent:
+		return QueuePriorityWrite
+	}
+	return QueuePrioritySend
+}
+
+// acquire blocks until the call can be sent. A successful acquire must be
+// followed by a release.
+func (q *Queue) acquire(ctx context.Context, priority int) error {
+	q.mu.Lock()
+	q.seq++
+	w := &waiter{
+		priority: priority,
+		seq:      q.seq,
+		ready:    make(chan struct{}),
+	}
+	heap.Push(&q.waiters, w)
+	q.dispatchLocked()
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.index >= 0 {
+			heap.Remove(&q.waiters, w.index)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+		// the slot was granted at the same time
+		q.mu.Unlock()
+		q.release()
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inFlight--
+	q.dispatchLocked()
+}
+
+func (q *Queue) dispatchLocked() {
+	for q.inFlight < q.cfg.MaxInFlight && len(q.waiters) > 0 {
+		if wait := q.takeTokenLocked(); wait > 0 {
+			if q.timer == nil {
+				q.timer = time.AfterFunc(wait, func() {
+					q.mu.Lock()
+					defer q.mu.Unlock()
+					q.timer = nil
+					q.dispatchLocked()
+				})
+			}
+			return
+		}
+		w := heap.Pop(&q.waiters).(*waiter)
+		q.inFlight++
+		close(w.ready)
+	}
+}
+
+// takeTokenLocked takes a token from the rate limiter. If there is none,
+// it returns how long to wait for the next one.
+func (q *Queue) takeTokenLocked() time.Duration {
+	if q.cfg.RateLimit <= 0 {
+		return 0
+	}
+	now := time.Now()
+	q.tokens = math.Min(float64(q.cfg.Burst), q.tokens+now.Sub(q.last).Seconds()*q.cfg.RateLimit)
+	q.last = now
+	if q.tokens >= 1 {
+		q.tokens--
+		return 0
+	}
+	wait := time.Duration((1 - q.tokens) / q.cfg.RateLimit * float64(time.Second))
+	if wait <= 0 {
+		wait = time.Millisecond
+	}
+	return wait
+}
+
+type waiter struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+}
+
+// waiterHeap is a max-heap by priority, then a min-heap by arrival.
+type waiterHeap []*waiter
+
+func (h waiterHeap) Len() int { return len(h) }
+
+func (h waiterHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waiterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waiterHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waiterHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	w := old[n-1]
+	old[n-1] = nil
+	w.index = -1
+	*h = old[:n-1]
+	return w
+}
@@ -0,0 +1,103 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuePriority(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	ctx := context.Background()
+	assert.NoError(t, q.acquire(ctx, 0))
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(method string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, q.acquire(ctx, q.priority(ctx, method)))
+			mu.Lock()
+			order = append(order, method)
+			mu.Unlock()
+			q.release()
+		}()
+	}
+	for _, method := range []string{"get_transfers", "store", "transfer", "getbalance"} {
+		n := q.Depth()
+		enqueue(method)
+		waitFor(t, func() bool { return q.Depth() == n+1 })
+	}
+	assert.Equal(t, 4, q.Depth())
+	assert.Equal(t, 1, q.InFlight())
+	q.release()
+	wg.Wait()
+	assert.Equal(t, []string{"transfer", "store", "get_transfers", "getbalance"}, order)
+	assert.Equal(t, 0, q.InFlight())
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := NewQueue(QueueConfig{})
+	assert.NoError(t, q.acquire(context.Background(), 0))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.acquire(ctx, 0))
+	assert.Equal(t, 0, q.Depth())
+	q.release()
+	assert.NoError(t, q.acquire(context.Background(), 0))
+}
+
+func TestQueueRateLimit(t *testing.T) {
+	q := NewQueue(QueueConfig{
+		MaxInFlight: 10,
+		RateLimit:   100,
+	})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, q.acquire(context.Background(), 0))
+		q.release()
+	}
+	assert.True(t, time.Since(start) >= 25*time.Millisecond)
+}
+
+func TestClientQueue(t *testing.T) {
+	var inflight, maxInflight int32
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			n := atomic.AddInt32(&inflight, 1)
+			defer atomic.AddInt32(&inflight, -1)
+			if n > atomic.LoadInt32(&maxInflight) {
+				atomic.StoreInt32(&maxInflight, n)
+			}
+			time.Sleep(5 * time.Millisecond)
+			writerpcResponseOK(H{"height": 1}, w)
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	q := NewQueue(QueueConfig{})
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Queue:   q,
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rpccl.GetHeight()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxInflight))
+}
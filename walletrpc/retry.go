@@ -0,0 +1,219 @@
+package walletrpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// MethodClass tells how safe it is to repeat a monero-wallet-rpc method.
+type MethodClass int
+
+const (
+	// MethodReadOnly methods do not change the wallet state and can be
+	// repeated freely.
+	MethodReadOnly MethodClass = iota
+	// MethodIdempotent methods change the wallet state, but running them
+	// twice has the same effect as running them once.
+	MethodIdempotent
+	// MethodNonIdempotent methods (transfers, sweeps...) must never be sent
+	// twice once the first request may have reached the wallet.
+	MethodNonIdempotent
+)
+
+func (mc MethodClass) String() string {
+	switch mc {
+	case MethodReadOnly:
+		return "read-only"
+	case MethodIdempotent:
+		return "idempotent"
+	case MethodNonIdempotent:
+		return "non-idempotent"
+	}
+	return "unknown"
+}
+
+var methodClasses = map[string]MethodClass{
+	"getbalance":               MethodReadOnly,
+	"getaddress":               MethodReadOnly,
+	"getheight":                MethodReadOnly,
+	"get_payments":             MethodReadOnly,
+	"get_bulk_payments":        MethodReadOnly,
+	"get_transfers":            MethodReadOnly,
+	"get_transfer_by_txid":     MethodReadOnly,
+	"incoming_transfers":       MethodReadOnly,
+	"query_key":                MethodReadOnly,
+	"make_integrated_address":  MethodReadOnly,
+	"split_integrated_address": MethodReadOnly,
+	"make_uri":                 MethodReadOnly,
+	"parse_uri":                MethodReadOnly,
+	"get_tx_notes":             MethodReadOnly,
+	"sign":                     MethodReadOnly,
+	"verify":                   MethodReadOnly,
+	"export_key_images":        MethodReadOnly,
+	"get_address_book":         MethodReadOnly,
+	"get_languages":            MethodReadOnly,
+	"store":                    MethodIdempotent,
+	"stop_wallet":              MethodIdempotent,
+	"rescan_blockchain":        MethodIdempotent,
+	"set_tx_notes":             MethodIdempotent,
+	"import_key_images":        MethodIdempotent,
+	"rescan_spent":             MethodIdempotent,
+	"start_mining":             MethodIdempotent,
+	"stop_mining":              MethodIdempotent,
+	"open_wallet":              MethodIdempotent,
+	"transfer":                 MethodNonIdempotent,
+	"transfer_split":           MethodNonIdempotent,
+	"sweep_dust":               MethodNonIdempotent,
+	"sweep_all":                MethodNonIdempotent,
+	"add_address_book":         MethodNonIdempotent,
+	"delete_address_book":      MethodNonIdempotent, // indexes shift after a delete
+	"create_wallet":            MethodNonIdempotent,
+
+	// accounts
+	"get_accounts":                MethodReadOnly,
+	"get_account_tags":            MethodReadOnly,
+	"label_account":               MethodIdempotent,
+	"tag_accounts":                MethodIdempotent,
+	"untag_accounts":              MethodIdempotent,
+	"set_account_tag_description": MethodIdempotent,
+	"create_account":              MethodNonIdempotent,
+
+	// subaddresses
+	"get_address":       MethodReadOnly,
+	"get_address_index": MethodReadOnly,
+	"label_address":     MethodIdempotent,
+	"create_address":    MethodNonIdempotent,
+}
+
+// ClassifyMethod returns the MethodClass of a monero-wallet-rpc method.
+// Unknown methods are reported as MethodNonIdempotent.
+func ClassifyMethod(method string) MethodClass {
+	if mc, ok := methodClasses[method]; ok {
+		return mc
+	}
+	return MethodNonIdempotent
+}
+
+// RetryPolicy configures how the client retries calls that failed with a
+// transient error (connection failures, HTTP 5xx and wallet errors of the
+// ErrorClassRetryable class, such as ErrDaemonIsBusy).
+//
+// Read-only and idempotent methods are retried on any transient error.
+// Non-idempotent methods (Transfer, TransferSplit, SweepAll, SweepDust...)
+// are only retried when the request provably never left the client: the
+// connection to the wallet could not be established, or the call failed
+// before the request was built. Any other failure may have reached the
+// wallet, so it is never retried for those methods.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values lower than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Multiplier is applied to the delay after every attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction (0 to 1).
+	Jitter float64
+	// Methods overrides the built-in classification of ClassifyMethod.
+	Methods map[string]MethodClass
+}
+
+func (p *RetryPolicy) attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) classify(method string) MethodClass {
+	if p != nil {
+		if mc, ok := p.Methods[method]; ok {
+			return mc
+		}
+	}
+	return ClassifyMethod(method)
+}
+
+// backoff returns the delay before the given retry (starting at 1).
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 2
+	}
+	d := float64(initial) * math.Pow(mult, float64(retry-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	if p.Jitter > 0 {
+		j := math.Min(p.Jitter, 1)
+		d += d * j * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// attemptState tells how far a single request went before it failed.
+type attemptState int
+
+const (
+	// the request provably never left the client
+	attemptNotSent attemptState = iota
+	// the request may have been written, but no response was read
+	attemptSent
+	// the wallet (or something in front of it) responded
+	attemptResponded
+)
+
+// isDialError tells if err happened while connecting to the wallet, before
+// anything was written. Errors of custom transports that do not dial are
+// not, so their calls are treated as sent.
+func isDialError(err error) bool {
+	var operr *net.OpError
+	return errors.As(err, &operr) && operr.Op == "dial"
+}
+
+// shouldRetry tells if a failed attempt can be repeated.
+func (p *RetryPolicy) shouldRetry(method string, state attemptState, err error) bool {
+	if state == attemptNotSent {
+		// nothing reached the wallet, so even transfers are safe
+		return true
+	}
+	if p.classify(method) == MethodNonIdempotent {
+		return false
+	}
+	if state == attemptSent {
+		// connection reset, unexpected EOF...
+		return true
+	}
+	if herr, ok := err.(*HTTPError); ok {
+		return herr.StatusCode >= 500
+	}
+	if iswerr, werr := GetWalletError(err); iswerr {
+		return werr.Code.Class() == ErrorClassRetryable
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
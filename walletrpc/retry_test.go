@@ -0,0 +1,152 @@
+package walletrpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryReadOnly(t *testing.T) {
+	var calls int32
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method != "getheight" {
+				return false
+			}
+			switch atomic.AddInt32(&calls, 1) {
+			case 1:
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			case 2:
+				writerpcResponseError(ErrDaemonIsBusy, "daemon is busy", w)
+			default:
+				writerpcResponseOK(H{"height": 1234}, w)
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Retry: &RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+	height, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1234), height)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			if method != "transfer" {
+				return false
+			}
+			atomic.AddInt32(&calls, 1)
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		Retry: &RetryPolicy{
+			MaxAttempts:    5,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+	_, err := rpccl.Transfer(TransferRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryNotSent(t *testing.T) {
+	p := &RetryPolicy{}
+	assert.True(t, p.shouldRetry("transfer", attemptNotSent, nil))
+	assert.False(t, p.shouldRetry("transfer", attemptSent, nil))
+	assert.True(t, p.shouldRetry("getbalance", attemptSent, nil))
+	assert.False(t, p.shouldRetry("getbalance", attemptResponded, &HTTPError{StatusCode: 404}))
+}
+
+// errTransport fails every request with err.
+type errTransport struct {
+	calls int32
+	err   error
+}
+
+func (t *errTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	return nil, t.err
+}
+
+func TestRetryTransportError(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}
+
+	// the transport may have sent the request
+	tr := &errTransport{err: errors.New("connection reset by peer")}
+	rpccl := New(Config{
+		Address:   "http://127.0.0.1/json_rpc",
+		Transport: tr,
+		Retry:     policy,
+	})
+	_, err := rpccl.Transfer(TransferRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tr.calls))
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&tr.calls))
+
+	// the connection could not be established
+	tr = &errTransport{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	rpccl = New(Config{
+		Address:   "http://127.0.0.1/json_rpc",
+		Transport: tr,
+		Retry:     policy,
+	})
+	_, err = rpccl.Transfer(TransferRequest{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&tr.calls))
+}
+
+func TestRetryDialError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	_, err = http.Post("http://"+addr+"/json_rpc", "application/json", nil)
+	assert.True(t, isDialError(err), "%v", err)
+	assert.False(t, isDialError(errors.New("unexpected EOF")))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Jitter:         0.5,
+	}
+	for i := 1; i < 10; i++ {
+		d := p.backoff(i)
+		assert.True(t, d >= 5*time.Millisecond, "retry %v: %v", i, d)
+		assert.True(t, d <= 75*time.Millisecond, "retry %v: %v", i, d)
+	}
+	p.Jitter = 0
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+}
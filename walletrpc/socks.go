@@ -0,0 +1,257 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// socksDialer dials through a SOCKS5 proxy (RFC 1928), such as Tor.
+// With remoteDNS, host names are sent to the proxy unresolved, so .onion
+// addresses work; otherwise they are resolved locally.
+type socksDialer struct {
+	proxyAddr string
+	username  string
+	password  string
+	remoteDNS bool
+	dialer    *net.Dialer
+	resolver  *net.Resolver
+}
+
+// newSOCKSDialer parses a socks5:// or socks5h:// proxy URL. Like curl,
+// socks5:// resolves host names locally and socks5h:// lets the proxy
+// resolve them. The user info of the URL is used for the username/password
+// authentication, which Tor uses to isolate streams.
+func newSOCKSDialer(proxy string) (*socksDialer, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("walletrpc: unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, errors.New("walletrpc: proxy address is missing")
+	}
+	d := &socksDialer{
+		proxyAddr: u.Host,
+		remoteDNS: u.Scheme == "socks5h",
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		resolver: net.DefaultResolver,
+	}
+	if u.Port() == "" {
+		d.proxyAddr = net.JoinHostPort(u.Hostname(), "1080")
+	}
+	if u.User != nil {
+		d.username = u.User.Username()
+		d.password, _ = u.User.Password()
+		if len(d.username) > 255 || len(d.password) > 255 {
+			return nil, errors.New("walletrpc: proxy username or password too long")
+		}
+	}
+	return d, nil
+}
+
+// DialContext connects to addr through the proxy.
+func (d *socksDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("walletrpc: network %v not supported by the SOCKS5 proxy", network)
+	}
+	if !d.remoteDNS {
+		var err error
+		if addr, err = d.resolve(ctx, addr); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// abort the handshake if the context is cancelled
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	err = d.handshake(conn, addr)
+	close(done)
+	// the deadline must not be set once the connection is returned
+	<-stopped
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// resolve replaces the host name of addr with one of its IP addresses,
+// IPv4 first.
+func (d *socksDialer) resolve(ctx context.Context, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ips, err := d.resolver.LookupIPAddr(ctx, host)
+	if err == nil && len(ips) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if err != nil {
+		return "", fmt.Errorf("walletrpc: %w (use a socks5h:// proxy to let the proxy resolve host names)", err)
+	}
+	ip := ips[0].IP
+	for _, a := range ips {
+		if a.IP.To4() != nil {
+			ip = a.IP
+			break
+		}
+	}
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+const (
+	socksVersion      = 5
+	socksAuthNone     = 0
+	socksAuthPassword = 2
+	socksAuthNoMethod = 0xff
+	socksCmdConnect   = 1
+	socksAddrIPv4     = 1
+	socksAddrDomain   = 3
+	socksAddrIPv6     = 4
+)
+
+var socksReplies = []string{
+	"succeeded",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+func (d *socksDialer) handshake(conn net.Conn, addr string) error {
+	host, portstr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("walletrpc: invalid port %q", portstr)
+	}
+
+	// greeting
+	methods := []byte{socksAuthNone}
+	if d.username != "" || d.password != "" {
+		methods = []byte{socksAuthPassword}
+	}
+	if _, err := conn.Write(append([]byte{socksVersion, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var buf [2]byte
+	if _, err := io.ReadFull(conn, buf[:]); err != nil {
+		return err
+	}
+	if buf[0] != socksVersion {
+		return fmt.Errorf("walletrpc: unexpected SOCKS version %v", buf[0])
+	}
+	switch buf[1] {
+	case socksAuthNone:
+	case socksAuthPassword:
+		req := []byte{1, byte(len(d.username))}
+		req = append(req, d.username...)
+		req = append(req, byte(len(d.password)))
+		req = append(req, d.password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, buf[:]); err != nil {
+			return err
+		}
+		if buf[1] != 0 {
+			return errors.New("walletrpc: SOCKS5 proxy rejected the username or password")
+		}
+	case socksAuthNoMethod:
+		return errors.New("walletrpc: SOCKS5 proxy requires an unsupported authentication method")
+	default:
+		return fmt.Errorf("walletrpc: unexpected SOCKS5 authentication method %v", buf[1])
+	}
+
+	// connect
+	req := []byte{socksVersion, socksCmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, socksAddrIPv4)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, socksAddrIPv6)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("walletrpc: host name too long: %v", host)
+		}
+		req = append(req, socksAddrDomain, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var reply [4]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		msg := "unknown error"
+		if int(reply[1]) < len(socksReplies) {
+			msg = socksReplies[reply[1]]
+		}
+		return fmt.Errorf("walletrpc: SOCKS5 proxy could not connect to %v: %v", addr, msg)
+	}
+	// skip the bound address
+	var skip int
+	switch reply[3] {
+	case socksAddrIPv4:
+		skip = net.IPv4len
+	case socksAddrIPv6:
+		skip = net.IPv6len
+	case socksAddrDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return fmt.Errorf("walletrpc: unexpected SOCKS5 address type %v", reply[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
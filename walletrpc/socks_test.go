@@ -0,0 +1,232 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// socksTestProxy is a minimal SOCKS5 server that connects every request to
+// target, recording the requested host and the credentials. A silent proxy
+// never answers.
+type socksTestProxy struct {
+	ln     net.Listener
+	target string
+	silent bool
+
+	mu    sync.Mutex
+	hosts []string
+	users []string
+}
+
+func newSOCKSTestProxy(t *testing.T, target string, silent bool) *socksTestProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &socksTestProxy{ln: ln, target: target, silent: silent}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *socksTestProxy) Close() { p.ln.Close() }
+
+func (p *socksTestProxy) serve(conn net.Conn) {
+	defer conn.Close()
+	if p.silent {
+		io.Copy(ioutil.Discard, conn)
+		return
+	}
+	var hdr [2]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	user := ""
+	if methods[0] == socksAuthPassword {
+		conn.Write([]byte{socksVersion, socksAuthPassword})
+		var b [2]byte
+		io.ReadFull(conn, b[:])
+		u := make([]byte, b[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, b[:1])
+		pw := make([]byte, b[0])
+		io.ReadFull(conn, pw)
+		if string(pw) != "secret" {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+		user = string(u)
+	} else {
+		conn.Write([]byte{socksVersion, socksAuthNone})
+	}
+	var req [4]byte
+	if _, err := io.ReadFull(conn, req[:]); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case socksAddrDomain:
+		var l [1]byte
+		io.ReadFull(conn, l[:])
+		b := make([]byte, l[0])
+		io.ReadFull(conn, b)
+		host = string(b)
+	case socksAddrIPv4:
+		b := make([]byte, 4)
+		io.ReadFull(conn, b)
+		host = net.IP(b).String()
+	}
+	var port [2]byte
+	io.ReadFull(conn, port[:])
+	p.mu.Lock()
+	p.hosts = append(p.hosts, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	p.users = append(p.users, user)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		conn.Write([]byte{socksVersion, 5, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{socksVersion, 0, 0, socksAddrIPv4, 127, 0, 0, 1, 0, 0})
+	go io.Copy(upstream, conn)
+	io.Copy(conn, upstream)
+}
+
+func TestSOCKSProxy(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			writerpcResponseOK(H{"height": 42}, w)
+			return true
+		},
+	})
+	defer sv0.Close()
+	proxy := newSOCKSTestProxy(t, sv0.Listener.Addr().String(), false)
+	defer proxy.Close()
+
+	// the .onion host name is only resolvable by the proxy
+	rpccl := New(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5h://" + proxy.ln.Addr().String(),
+	})
+	height, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), height)
+
+	// stream isolation credentials
+	rpccl = New(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5h://circuit1:secret@" + proxy.ln.Addr().String(),
+	})
+	_, err = rpccl.GetHeight()
+	assert.NoError(t, err)
+
+	rpccl = New(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5h://circuit1:wrong@" + proxy.ln.Addr().String(),
+	})
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+
+	proxy.mu.Lock()
+	assert.Equal(t, []string{
+		"walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082",
+		"walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082",
+	}, proxy.hosts)
+	assert.Equal(t, []string{"", "circuit1"}, proxy.users)
+	proxy.mu.Unlock()
+}
+
+func TestSOCKSProxyLocalDNS(t *testing.T) {
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			writerpcResponseOK(H{"height": 42}, w)
+			return true
+		},
+	})
+	defer sv0.Close()
+	proxy := newSOCKSTestProxy(t, sv0.Listener.Addr().String(), false)
+	defer proxy.Close()
+
+	// socks5 resolves the host name itself
+	rpccl := New(Config{
+		Address: "http://localhost:18082/json_rpc",
+		Proxy:   "socks5://" + proxy.ln.Addr().String(),
+	})
+	height, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), height)
+
+	// and can't reach .onion addresses
+	rpccl = New(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5://" + proxy.ln.Addr().String(),
+	})
+	_, err = rpccl.GetHeight()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "socks5h://")
+	}
+
+	proxy.mu.Lock()
+	assert.Equal(t, []string{"127.0.0.1:18082"}, proxy.hosts)
+	proxy.mu.Unlock()
+}
+
+func TestSOCKSProxyTimeout(t *testing.T) {
+	proxy := newSOCKSTestProxy(t, "", true)
+	defer proxy.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5h://" + proxy.ln.Addr().String(),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := rpccl.GetHeightContext(ctx)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	rpccl = NewClientContext(Config{
+		Address: "http://walletrpcxxxxxxxxxxxxxxxxxxxxxxxx.onion:18082/json_rpc",
+		Proxy:   "socks5h://" + proxy.ln.Addr().String(),
+		Timeout: 100 * time.Millisecond,
+	})
+	start = time.Now()
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestSOCKSProxyInvalid(t *testing.T) {
+	rpccl := New(Config{
+		Address: "http://127.0.0.1:18082/json_rpc",
+		Proxy:   "http://127.0.0.1:8080",
+	})
+	_, err := rpccl.GetHeight()
+	assert.Error(t, err)
+}
@@ -0,0 +1,256 @@
+package walletrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// streamDecoder is implemented by results that are decoded element by
+// element instead of being read into memory at once.
+type streamDecoder interface {
+	decodeStream(dec *json.Decoder, strict bool) error
+}
+
+func (c *client) GetTransfersStream(ctx context.Context, req GetTransfersRequest, fn func(t Transfer) error) error {
+	return c.do(ctx, "get_transfers", &req, &transferStream{fn})
+}
+
+func (c *client) IncomingTransfersStream(ctx context.Context, transfertype GetTransferType, fn func(t IncTransfer) error) error {
+	jin := struct {
+		TransferType GetTransferType `json:"transfer_type"`
+	}{
+		transfertype,
+	}
+	return c.do(ctx, "incoming_transfers", &jin, &incTransferStream{fn})
+}
+
+// transferStream decodes the in/out/pending/failed/pool arrays of a
+// get_transfers result.
+type transferStream struct {
+	fn func(t Transfer) error
+}
+
+func (s *transferStream) decodeStream(dec *json.Decoder, strict bool) error {
+	return decodeObjectArrays(dec, func(key string) error {
+		switch key {
+		case "in", "out", "pending", "failed", "pool":
+		default:
+			return unknownField(key, strict)
+		}
+		return decodeArray(dec, func() error {
+			var t Transfer
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			if t.Type == "" {
+				t.Type = key
+			}
+			return s.fn(t)
+		})
+	})
+}
+
+// incTransferStream decodes the transfers array of an incoming_transfers
+// result.
+type incTransferStream struct {
+	fn func(t IncTransfer) error
+}
+
+func (s *incTransferStream) decodeStream(dec *json.Decoder, strict bool) error {
+	return decodeObjectArrays(dec, func(key string) error {
+		if key != "transfers" {
+			return unknownField(key, strict)
+		}
+		return decodeArray(dec, func() error {
+			var t IncTransfer
+			if err := dec.Decode(&t); err != nil {
+				return err
+			}
+			return s.fn(t)
+		})
+	})
+}
+
+var (
+	errSkipValue = errors.New("skip value")
+	errNullValue = errors.New("null value")
+)
+
+// unknownField skips the value of an unknown result key, or reports it in
+// strict mode the way json.Decoder does with DisallowUnknownFields, so
+// asUnknownFieldError turns it into an UnknownFieldError.
+func unknownField(key string, strict bool) error {
+	if !strict {
+		return errSkipValue
+	}
+	return fmt.Errorf("json: unknown field %q", key)
+}
+
+// decodeObjectArrays walks the keys of a JSON object, calling fn to decode
+// the value of each one. fn returns errSkipValue to ignore a value. A null
+// object is reported with errNullValue.
+func decodeObjectArrays(dec *json.Decoder, fn func(key string) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return errNullValue
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("walletrpc: expected {, got %v", tok)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		err = fn(key)
+		if err == errSkipValue {
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray calls fn for every element of a JSON array. A null value is
+// treated as an empty array.
+func decodeArray(dec *json.Decoder, fn func() error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		return nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("walletrpc: expected array, got %v", tok)
+	}
+	for dec.More() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("walletrpc: expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var v json.RawMessage
+	return dec.Decode(&v)
+}
+
+// decodeStreamResponse decodes a JSON-RPC response of at most max bytes (no
+// limit when negative), handing the result to out as it is read.
+//
+// out only sees the result once the envelope has been checked: the jsonrpc
+// version and id must come before it, and no error. monero-wallet-rpc
+// writes them in that order; otherwise the result is read into memory and
+// handed to out after the whole response has been validated.
+func decodeStreamResponse(r io.Reader, id uint64, method string, out streamDecoder, strict bool, max int64) error {
+	if max >= 0 {
+		r = &limitedReader{r: r, n: max}
+	}
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	var (
+		resp     rpcResponse
+		idOK     bool
+		buffered json.RawMessage
+	)
+	err := decodeObjectArrays(dec, func(key string) error {
+		switch key {
+		case "jsonrpc":
+			return dec.Decode(&resp.Version)
+		case "id":
+			if err := dec.Decode(&resp.ID); err != nil {
+				return err
+			}
+			rid, ok := resp.id()
+			if ok && rid != id {
+				return fmt.Errorf("%w: response id %s does not match request id %v", ErrInvalidResponse, resp.ID, id)
+			}
+			idOK = ok
+			return nil
+		case "error":
+			return dec.Decode(&resp.Error)
+		case "result":
+			if resp.Version != "2.0" || !idOK || resp.Error != nil {
+				return dec.Decode(&buffered)
+			}
+			err := out.decodeStream(dec, strict)
+			if err == errNullValue {
+				return nil
+			}
+			resp.Result = &json.RawMessage{}
+			return err
+		}
+		return errSkipValue
+	})
+	if err != nil {
+		return asUnknownFieldError(method, err)
+	}
+	buffer := buffered != nil && string(buffered) != "null"
+	if buffer {
+		resp.Result = &buffered
+	}
+	if err := resp.validate(id); err != nil {
+		return err
+	}
+	if buffer && resp.Error == nil {
+		bdec := json.NewDecoder(bytes.NewReader(buffered))
+		if strict {
+			bdec.DisallowUnknownFields()
+		}
+		if err := out.decodeStream(bdec, strict); err != nil {
+			return asUnknownFieldError(method, err)
+		}
+	}
+	if resp.Result != nil {
+		// already decoded
+		*resp.Result = json.RawMessage("{}")
+	}
+	return resp.decodeResult(method, nil, false)
+}
+
+// limitedReader reads from r until more than n bytes are read, then fails
+// with ErrResponseTooLarge.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n + int(l.n), ErrResponseTooLarge
+	}
+	return n, err
+}
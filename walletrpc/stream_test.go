@@ -0,0 +1,152 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransfersStream(t *testing.T) {
+	const n = 20000
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			switch method {
+			case "get_transfers":
+				// write the response by hand, as a huge stream
+				fmt.Fprintf(w, `{"id":%v,"jsonrpc":"2.0","result":{"in":[`, idOf(w))
+				for i := 0; i < n; i++ {
+					if i > 0 {
+						w.Write([]byte(","))
+					}
+					fmt.Fprintf(w, `{"txid":"in%d","amount":%d,"type":"in"}`, i, i)
+				}
+				w.Write([]byte(`],"out":[{"txid":"out0","amount":7}],"pending":null,"extra":{"a":[1,2]}}}`))
+			case "incoming_transfers":
+				writerpcResponseOK(H{"transfers": []IncTransfer{{Amount: 1, TxHash: "a"}, {Amount: 2, TxHash: "b"}}}, w)
+			default:
+				return false
+			}
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	ctx := context.Background()
+	var count int
+	var sum uint64
+	var out []Transfer
+	err := rpccl.GetTransfersStream(ctx, GetTransfersRequest{In: true, Out: true}, func(tr Transfer) error {
+		if tr.Type == "out" {
+			out = append(out, tr)
+			return nil
+		}
+		count++
+		sum += tr.Amount
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, n, count)
+	assert.Equal(t, uint64(n*(n-1)/2), sum)
+	assert.Equal(t, []Transfer{{TxID: "out0", Amount: 7, Type: "out"}}, out)
+
+	// the callback aborts the call
+	errStop := errors.New("stop")
+	count = 0
+	err = rpccl.GetTransfersStream(ctx, GetTransfersRequest{In: true}, func(tr Transfer) error {
+		count++
+		if count == 10 {
+			return errStop
+		}
+		return nil
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, 10, count)
+
+	var inc []string
+	err = rpccl.IncomingTransfersStream(ctx, TransferAll, func(tr IncTransfer) error {
+		inc = append(inc, tr.TxHash)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, inc)
+}
+
+func TestStreamWalletError(t *testing.T) {
+	sv0 := basicTestServer(nil)
+	defer sv0.Close()
+
+	rpccl := NewClientContext(Config{
+		Address: sv0.URL + "/json_rpc",
+	})
+	err := rpccl.GetTransfersStream(context.Background(), GetTransfersRequest{}, func(tr Transfer) error {
+		t.Fatal("unexpected transfer")
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrUnknown), "%v", err)
+}
+
+func TestStreamResponseChecks(t *testing.T) {
+	var body string
+	sv0 := basicTestServer([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			fmt.Fprintf(w, body, idOf(w))
+			return true
+		},
+	})
+	defer sv0.Close()
+
+	get := func(cfg Config) ([]string, error) {
+		cfg.Address = sv0.URL + "/json_rpc"
+		var txids []string
+		err := NewClientContext(cfg).GetTransfersStream(context.Background(), GetTransfersRequest{In: true}, func(tr Transfer) error {
+			txids = append(txids, tr.TxID)
+			return nil
+		})
+		return txids, err
+	}
+
+	// the response size is limited
+	body = `{"id":%v,"jsonrpc":"2.0","result":{"in":[{"txid":"a"},{"txid":"b"},{"txid":"c"},{"txid":"d"}]}}`
+	_, err := get(Config{MaxResponseSize: 64})
+	assert.True(t, errors.Is(err, ErrResponseTooLarge), "%v", err)
+	txids, err := get(Config{MaxResponseSize: int64(len(body) + 16)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, txids)
+
+	// strict mode reports unknown result keys
+	body = `{"id":%v,"jsonrpc":"2.0","result":{"in":[{"txid":"a"}],"extra":1}}`
+	_, err = get(Config{StrictDecoding: true})
+	uerr := &UnknownFieldError{}
+	if assert.True(t, errors.As(err, &uerr), "%v", err) {
+		assert.Equal(t, "get_transfers", uerr.Method)
+		assert.Equal(t, "extra", uerr.Field)
+	}
+	txids, err = get(Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, txids)
+
+	// the result is held back until the id is checked
+	body = `{"result":{"in":[{"txid":"a"}]},"jsonrpc":"2.0","id":"%v"}`
+	txids, err = get(Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, txids)
+	body = `{"result":{"in":[{"txid":"a"}]},"jsonrpc":"2.0","id":1%v}`
+	txids, err = get(Config{})
+	assert.True(t, errors.Is(err, ErrInvalidResponse), "%v", err)
+	assert.Empty(t, txids)
+
+	// and is not handed out with an error
+	body = `{"id":%v,"jsonrpc":"2.0","error":{"code":-1,"message":"boom"},"result":{"in":[{"txid":"a"}]}}`
+	txids, err = get(Config{})
+	werr := &WalletError{}
+	assert.True(t, errors.As(err, &werr), "%v", err)
+	assert.Empty(t, txids)
+}
@@ -180,3 +180,77 @@ type AddressBookEntry struct {
 	Index       uint64 `json:"index,omitempty"`
 	PaymentID   string `json:"payment_id,omitempty"`
 }
+
+// Account is a subaddress account returned by GetAccounts().
+type Account struct {
+	// account_index - unsigned int; Index of the account.
+	AccountIndex uint64 `json:"account_index"`
+	// balance - unsigned int; Balance of the account, in atomic units.
+	Balance uint64 `json:"balance"`
+	// base_address - string; Address of the account (its subaddress 0).
+	BaseAddress string `json:"base_address"`
+	// label - string; Label of the account.
+	Label string `json:"label"`
+	// tag - string; Tag of the account.
+	Tag string `json:"tag"`
+	// unlocked_balance - unsigned int; Unlocked balance of the account, in atomic units.
+	UnlockedBalance uint64 `json:"unlocked_balance"`
+}
+
+// GetAccountsResponse is the result of GetAccounts()
+type GetAccountsResponse struct {
+	SubaddressAccounts []Account `json:"subaddress_accounts"`
+	// total_balance - unsigned int; Balance of the returned accounts.
+	TotalBalance uint64 `json:"total_balance"`
+	// total_unlocked_balance - unsigned int; Unlocked balance of the returned accounts.
+	TotalUnlockedBalance uint64 `json:"total_unlocked_balance"`
+}
+
+// AccountTag is an account tag returned by GetAccountTags()
+type AccountTag struct {
+	// tag - string; Name of the tag.
+	Tag string `json:"tag"`
+	// label - string; Description of the tag.
+	Label string `json:"label"`
+	// accounts - array of unsigned int; Indexes of the tagged accounts.
+	Accounts []uint64 `json:"accounts"`
+}
+
+// SubaddressIndex locates an address of the wallet.
+type SubaddressIndex struct {
+	// major - unsigned int; Account index.
+	Major uint64 `json:"major"`
+	// minor - unsigned int; Subaddress index in the account (0 for its base address).
+	Minor uint64 `json:"minor"`
+}
+
+// Subaddress is an address of an account returned by GetAddresses().
+type Subaddress struct {
+	// address - string; The address.
+	Address string `json:"address"`
+	// label - string; Label of the address.
+	Label string `json:"label"`
+	// address_index - unsigned int; Index of the subaddress in the account.
+	AddressIndex uint64 `json:"address_index"`
+	// used - boolean; States if the address has received funds.
+	Used bool `json:"used"`
+}
+
+// GetAddressResponse is the result of GetAddresses()
+type GetAddressResponse struct {
+	// address - string; Base address of the account.
+	Address   string       `json:"address"`
+	Addresses []Subaddress `json:"addresses"`
+}
+
+// CreateAddressResponse is the result of CreateAddress()
+type CreateAddressResponse struct {
+	// address - string; The first created address.
+	Address string `json:"address"`
+	// address_index - unsigned int; Index of the first created address.
+	AddressIndex uint64 `json:"address_index"`
+	// addresses - array of string; Every created address.
+	Addresses []string `json:"addresses"`
+	// address_indices - array of unsigned int; Index of every created address.
+	AddressIndices []uint64 `json:"address_indices"`
+}
@@ -0,0 +1,119 @@
+package walletrpc
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrFingerprintMismatch is returned when the server certificate does not
+// match any of the pinned fingerprints.
+var ErrFingerprintMismatch = errors.New("walletrpc: server certificate fingerprint mismatch")
+
+// TLSConfig holds the TLS settings used to reach a monero-wallet-rpc started
+// with --rpc-ssl.
+type TLSConfig struct {
+	// CAFile or CAPEM is a PEM bundle of the CAs allowed to sign the server
+	// certificate. The system roots are used when both are empty.
+	CAFile string
+	CAPEM  []byte
+	// Fingerprints are the allowed SHA-256 fingerprints of the server
+	// certificate, in hex (colons are ignored), like the
+	// --rpc-ssl-allowed-fingerprints option of monero-wallet-rpc. When set,
+	// a self-signed certificate is accepted if it matches, and any
+	// certificate that does not match is rejected. If a CA is also set, the
+	// certificate must match both.
+	Fingerprints []string
+	// CertFile and KeyFile (or CertPEM and KeyPEM) are the client
+	// certificate and key used for mutual TLS.
+	CertFile string
+	KeyFile  string
+	CertPEM  []byte
+	KeyPEM   []byte
+	// ServerName overrides the host name used to verify the server
+	// certificate.
+	ServerName string
+}
+
+// Build returns the crypto/tls configuration described by tc.
+func (tc *TLSConfig) Build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: tc.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	capem := tc.CAPEM
+	if tc.CAFile != "" {
+		buf, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		capem = append(append([]byte(nil), capem...), buf...)
+	}
+	if len(capem) > 0 {
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(capem) {
+			return nil, errors.New("walletrpc: no certificate found in the CA bundle")
+		}
+	}
+
+	certpem, keypem := tc.CertPEM, tc.KeyPEM
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		var err error
+		if certpem, err = ioutil.ReadFile(tc.CertFile); err != nil {
+			return nil, err
+		}
+		if keypem, err = ioutil.ReadFile(tc.KeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if len(certpem) > 0 || len(keypem) > 0 {
+		cert, err := tls.X509KeyPair(certpem, keypem)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(tc.Fingerprints) > 0 {
+		pins := make(map[string]bool, len(tc.Fingerprints))
+		for _, fp := range tc.Fingerprints {
+			pin, err := parseFingerprint(fp)
+			if err != nil {
+				return nil, err
+			}
+			pins[pin] = true
+		}
+		// without a CA, the pin alone authenticates the (usually
+		// self-signed) certificate
+		cfg.InsecureSkipVerify = cfg.RootCAs == nil
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !pins[CertificateFingerprint(rawCerts[0])] {
+				return ErrFingerprintMismatch
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a DER encoded
+// certificate, in the format accepted by TLSConfig.Fingerprints.
+func CertificateFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func parseFingerprint(fp string) (string, error) {
+	pin := strings.ToLower(strings.Replace(strings.TrimSpace(fp), ":", "", -1))
+	buf, err := hex.DecodeString(pin)
+	if err != nil || len(buf) != sha256.Size {
+		return "", fmt.Errorf("walletrpc: invalid SHA-256 fingerprint %q", fp)
+	}
+	return pin, nil
+}
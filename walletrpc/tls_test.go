@@ -0,0 +1,147 @@
+package walletrpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tlsTestServer(clientCAs *x509.CertPool) *httptest.Server {
+	sv := httptest.NewUnstartedServer(basicTestHandler([]testfn{
+		func(method string, params *json.RawMessage, w http.ResponseWriter, r *http.Request) bool {
+			writerpcResponseOK(H{"height": 42}, w)
+			return true
+		},
+	}))
+	if clientCAs != nil {
+		sv.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		}
+	}
+	sv.StartTLS()
+	return sv
+}
+
+func TestTLSFingerprint(t *testing.T) {
+	sv0 := tlsTestServer(nil)
+	defer sv0.Close()
+	fp := CertificateFingerprint(sv0.Certificate().Raw)
+
+	// colon separated, upper case
+	var parts []string
+	for i := 0; i < len(fp); i += 2 {
+		parts = append(parts, strings.ToUpper(fp[i:i+2]))
+	}
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			Fingerprints: []string{strings.Join(parts, ":")},
+		},
+	})
+	height, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(42), height)
+
+	rpccl = New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			Fingerprints: []string{strings.Repeat("ab", 32)},
+		},
+	})
+	_, err = rpccl.GetHeight()
+	assert.True(t, errors.Is(err, ErrFingerprintMismatch), "%v", err)
+
+	rpccl = New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			Fingerprints: []string{"nope"},
+		},
+	})
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+}
+
+func TestTLSCA(t *testing.T) {
+	sv0 := tlsTestServer(nil)
+	defer sv0.Close()
+	capem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: sv0.Certificate().Raw})
+
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			CAPEM: capem,
+		},
+	})
+	_, err := rpccl.GetHeight()
+	assert.NoError(t, err)
+
+	// without the CA the certificate is rejected
+	rpccl = New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS:     &TLSConfig{},
+	})
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "walletrpc test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyder, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	sv0 := tlsTestServer(pool)
+	defer sv0.Close()
+	fp := CertificateFingerprint(sv0.Certificate().Raw)
+
+	rpccl := New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			Fingerprints: []string{fp},
+			CertPEM:      pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			KeyPEM:       pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyder}),
+		},
+	})
+	_, err = rpccl.GetHeight()
+	assert.NoError(t, err)
+
+	rpccl = New(Config{
+		Address: sv0.URL + "/json_rpc",
+		TLS: &TLSConfig{
+			Fingerprints: []string{fp},
+		},
+	})
+	_, err = rpccl.GetHeight()
+	assert.Error(t, err)
+}
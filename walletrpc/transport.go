@@ -0,0 +1,50 @@
+package walletrpc
+
+import (
+	"errors"
+	"net/http"
+)
+
+// buildTransport returns the http.RoundTripper described by cfg.
+func buildTransport(cfg Config) (http.RoundTripper, error) {
+	transport := cfg.Transport
+	if cfg.TLS != nil || cfg.Proxy != "" {
+		base, err := baseTransport(cfg.Transport)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.TLS != nil {
+			tlscfg, err := cfg.TLS.Build()
+			if err != nil {
+				return nil, err
+			}
+			base.TLSClientConfig = tlscfg
+		}
+		if cfg.Proxy != "" {
+			dialer, err := newSOCKSDialer(cfg.Proxy)
+			if err != nil {
+				return nil, err
+			}
+			base.Proxy = nil
+			base.DialContext = dialer.DialContext
+		}
+		transport = base
+	}
+	if cfg.Username != "" || cfg.Password != "" {
+		transport = newDigestTransport(cfg.Username, cfg.Password, transport)
+	}
+	return transport, nil
+}
+
+// baseTransport returns a copy of t (or of http.DefaultTransport) that can
+// be customized.
+func baseTransport(t http.RoundTripper) (*http.Transport, error) {
+	if t == nil {
+		return http.DefaultTransport.(*http.Transport).Clone(), nil
+	}
+	ht, ok := t.(*http.Transport)
+	if !ok {
+		return nil, errors.New("walletrpc: Config.Transport must be a *http.Transport to be combined with the TLS or Proxy options")
+	}
+	return ht.Clone(), nil
+}
@@ -0,0 +1,740 @@
+package walletrpctest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+// The fixture wallet of RunConformance.
+const (
+	// FixtureHeight is the blockchain height of the fixture.
+	FixtureHeight = 1000
+	// FixturePaymentID is the payment id of the first incoming transfer.
+	FixturePaymentID = "4279257e0a20608e"
+	// FixtureNote is the note of the first incoming transfer.
+	FixtureNote = "fixture"
+	// FixtureWalletFile and FixturePassword can be opened with OpenWallet.
+	FixtureWalletFile = "fixture"
+	FixturePassword   = "fixture password"
+)
+
+// Factory returns the walletrpc.Client under test, backed by w. It usually
+// wraps w, or a walletrpc client using w as its Transport, or a Server
+// serving w. If the client implements io.Closer, it is closed at the end of
+// each test.
+type Factory func(t *testing.T, w *Wallet) walletrpc.Client
+
+// NewFixture returns the Wallet used by RunConformance: a wallet at
+// FixtureHeight with
+//
+//   - 10 XMR received at height 900 with FixturePaymentID and FixtureNote
+//   - 2 XMR received at height 950
+//   - 1 XMR in the pool
+//   - an address book entry for its own address, described as "self"
+//   - the FixtureWalletFile wallet file, with FixturePassword
+func NewFixture() *Wallet {
+	w := NewWallet()
+	w.SetHeight(FixtureHeight)
+	txid := w.AddPayment(walletrpc.Payment{PaymentID: FixturePaymentID, Amount: 10e12, BlockHeight: 900})
+	w.SetTxNote(txid, FixtureNote)
+	w.AddIncomingTransfer(walletrpc.Transfer{Amount: 2e12, Height: 950})
+	w.AddIncomingTransfer(walletrpc.Transfer{Amount: 1e12, Type: "pool"})
+	w.AddAddressBookEntry(walletrpc.AddressBookEntry{Address: DefaultAddress, Description: "self"})
+	w.AddWalletFile(FixtureWalletFile, FixturePassword)
+	return w
+}
+
+// RunConformance checks that the clients returned by factory behave like
+// monero-wallet-rpc. Every method of walletrpc.Client is called against a
+// NewFixture wallet, checking the results, the error codes and that
+// state-changing calls are visible to the following calls and are sent to
+// the wallet exactly once. Each test runs as a subtest with its own
+// fixture. The wallet file checks are skipped for clients failing
+// OpenWallet with walletrpc.ErrWalletSelection, like walletrpc.Pool.
+func RunConformance(t *testing.T, factory Factory) {
+	for _, ct := range conformanceTests {
+		ct := ct
+		t.Run(ct.name, func(t *testing.T) {
+			w := NewFixture()
+			c := factory(t, w)
+			if closer, ok := c.(io.Closer); ok {
+				defer closer.Close()
+			}
+			ct.fn(t, c, w)
+		})
+	}
+}
+
+var conformanceTests = []struct {
+	name string
+	fn   func(t *testing.T, c walletrpc.Client, w *Wallet)
+}{
+	{"GetBalance", testGetBalance},
+	{"GetAddress", testGetAddress},
+	{"GetHeight", testGetHeight},
+	{"Transfer", testTransfer},
+	{"TransferSplit", testTransferSplit},
+	{"SweepDust", testSweepDust},
+	{"SweepAll", testSweepAll},
+	{"Store", testStore},
+	{"GetPayments", testGetPayments},
+	{"GetBulkPayments", testGetBulkPayments},
+	{"GetTransfers", testGetTransfers},
+	{"GetTransferByTxID", testGetTransferByTxID},
+	{"IncomingTransfers", testIncomingTransfers},
+	{"QueryKey", testQueryKey},
+	{"IntegratedAddress", testIntegratedAddress},
+	{"URI", testURI},
+	{"Rescan", testRescan},
+	{"Mining", testMining},
+	{"TxNotes", testTxNotes},
+	{"SignVerify", testSignVerify},
+	{"KeyImages", testKeyImages},
+	{"AddressBook", testAddressBook},
+	{"GetLanguages", testGetLanguages},
+	{"WalletFiles", testWalletFiles},
+	{"Accounts", testAccounts},
+	{"AccountTags", testAccountTags},
+	{"Subaddresses", testSubaddresses},
+	{"Call", testCall},
+}
+
+// noError fails the test now if err is not nil.
+func noError(t *testing.T, err error, call string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%v: unexpected error: %v", call, err)
+	}
+}
+
+// hasCode checks that err is a walletrpc.WalletError with code.
+func hasCode(t *testing.T, err error, code walletrpc.ErrorCode, call string) {
+	t.Helper()
+	var werr *walletrpc.WalletError
+	if !errors.As(err, &werr) {
+		t.Errorf("%v: want wallet error %v (%d), got %v", call, code, code, err)
+		return
+	}
+	if werr.Code != code {
+		t.Errorf("%v: want wallet error %v (%d), got %d: %v", call, code, code, werr.Code, werr.Message)
+	}
+}
+
+func equal(t *testing.T, want, got interface{}, what string) bool {
+	t.Helper()
+	if want != got {
+		t.Errorf("%v: want %v, got %v", what, want, got)
+		return false
+	}
+	return true
+}
+
+// sentTimes checks that method reached the wallet n times.
+func sentTimes(t *testing.T, w *Wallet, method string, n int) {
+	t.Helper()
+	if calls := w.Calls(method); calls != n {
+		t.Errorf("%v was sent %v times to the wallet, want %v", method, calls, n)
+	}
+}
+
+func testGetBalance(t *testing.T, c walletrpc.Client, w *Wallet) {
+	balance, unlocked, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, uint64(12e12), balance, "balance")
+	equal(t, uint64(12e12), unlocked, "unlocked balance")
+}
+
+func testGetAddress(t *testing.T, c walletrpc.Client, w *Wallet) {
+	addr, err := c.GetAddress()
+	noError(t, err, "GetAddress")
+	equal(t, DefaultAddress, addr, "address")
+}
+
+func testGetHeight(t *testing.T, c walletrpc.Client, w *Wallet) {
+	height, err := c.GetHeight()
+	noError(t, err, "GetHeight")
+	equal(t, uint64(FixtureHeight), height, "height")
+}
+
+func testTransfer(t *testing.T, c walletrpc.Client, w *Wallet) {
+	// read the balance first, so a cached value would show
+	balance, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+
+	resp, err := c.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+		PaymentID:    FixturePaymentID,
+		GetTxKey:     true,
+	})
+	noError(t, err, "Transfer")
+	equal(t, DefaultFee, resp.Fee, "fee")
+	equal(t, 64, len(resp.TxHash), "tx hash length")
+	equal(t, 64, len(resp.TxKey), "tx key length")
+	sentTimes(t, w, "transfer", 1)
+
+	after, unlocked, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, balance-1e12-DefaultFee, after, "balance after Transfer")
+	equal(t, balance-1e12-DefaultFee, unlocked, "unlocked balance after Transfer")
+
+	tr, err := c.GetTransferByTxID(resp.TxHash)
+	noError(t, err, "GetTransferByTxID")
+	equal(t, "out", tr.Type, "transfer type")
+	equal(t, uint64(1e12), tr.Amount, "transfer amount")
+	equal(t, FixturePaymentID, tr.PaymentID, "transfer payment id")
+
+	// not relayed
+	_, err = c.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+		DoNotRelay:   true,
+	})
+	noError(t, err, "Transfer")
+	same, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, after, same, "balance after a Transfer not relayed")
+
+	_, err = c.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 100e12, Address: DefaultAddress}},
+	})
+	hasCode(t, err, walletrpc.ErrNotEnoughMoney, "Transfer")
+	_, err = c.Transfer(walletrpc.TransferRequest{})
+	hasCode(t, err, walletrpc.ErrZeroDestination, "Transfer")
+	_, err = c.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+		PaymentID:    "nothex",
+	})
+	hasCode(t, err, walletrpc.ErrWrongPaymentID, "Transfer")
+	sentTimes(t, w, "transfer", 5)
+}
+
+func testTransferSplit(t *testing.T, c walletrpc.Client, w *Wallet) {
+	balance, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+
+	resp, err := c.TransferSplit(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+		GetTxKey:     true,
+	})
+	noError(t, err, "TransferSplit")
+	if len(resp.TxHashList) == 0 || len(resp.TxHashList) != len(resp.FeeList) ||
+		len(resp.TxHashList) != len(resp.AmountList) || len(resp.TxHashList) != len(resp.TxKeyList) {
+		t.Fatalf("TransferSplit: inconsistent lists: %+v", resp)
+	}
+	var amount, fee uint64
+	for i := range resp.TxHashList {
+		amount += resp.AmountList[i]
+		fee += resp.FeeList[i]
+	}
+	equal(t, uint64(1e12), amount, "amount")
+	sentTimes(t, w, "transfer_split", 1)
+
+	after, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, balance-amount-fee, after, "balance after TransferSplit")
+
+	_, err = c.TransferSplit(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 100e12, Address: DefaultAddress}},
+	})
+	hasCode(t, err, walletrpc.ErrNotEnoughMoney, "TransferSplit")
+}
+
+func testSweepDust(t *testing.T, c walletrpc.Client, w *Wallet) {
+	hashes, err := c.SweepDust()
+	noError(t, err, "SweepDust")
+	equal(t, 0, len(hashes), "swept transactions")
+	sentTimes(t, w, "sweep_dust", 1)
+}
+
+func testSweepAll(t *testing.T, c walletrpc.Client, w *Wallet) {
+	_, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+
+	resp, err := c.SweepAll(walletrpc.SweepAllRequest{Address: DefaultAddress, GetTxKeys: true})
+	noError(t, err, "SweepAll")
+	if len(resp.TxHashList) == 0 || len(resp.TxHashList) != len(resp.TxKeyList) {
+		t.Fatalf("SweepAll: inconsistent lists: %+v", resp)
+	}
+	sentTimes(t, w, "sweep_all", 1)
+
+	_, unlocked, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, uint64(0), unlocked, "unlocked balance after SweepAll")
+
+	_, err = c.SweepAll(walletrpc.SweepAllRequest{Address: DefaultAddress})
+	hasCode(t, err, walletrpc.ErrTxNotPossible, "SweepAll")
+	sentTimes(t, w, "sweep_all", 2)
+}
+
+func testStore(t *testing.T, c walletrpc.Client, w *Wallet) {
+	noError(t, c.Store(), "Store")
+	sentTimes(t, w, "store", 1)
+}
+
+func testGetPayments(t *testing.T, c walletrpc.Client, w *Wallet) {
+	payments, err := c.GetPayments(FixturePaymentID)
+	noError(t, err, "GetPayments")
+	if len(payments) != 1 {
+		t.Fatalf("GetPayments: want 1 payment, got %v", len(payments))
+	}
+	p := payments[0]
+	equal(t, FixturePaymentID, p.PaymentID, "payment id")
+	equal(t, uint64(10e12), p.Amount, "payment amount")
+	equal(t, uint64(900), p.BlockHeight, "payment height")
+	equal(t, 64, len(p.TxHash), "payment tx hash length")
+
+	payments, err = c.GetPayments("0000000000000000")
+	noError(t, err, "GetPayments")
+	equal(t, 0, len(payments), "payments of an unknown payment id")
+
+	_, err = c.GetPayments("nothex")
+	hasCode(t, err, walletrpc.ErrWrongPaymentID, "GetPayments")
+}
+
+func testGetBulkPayments(t *testing.T, c walletrpc.Client, w *Wallet) {
+	payments, err := c.GetBulkPayments([]string{FixturePaymentID}, 0)
+	noError(t, err, "GetBulkPayments")
+	equal(t, 1, len(payments), "payments")
+	payments, err = c.GetBulkPayments([]string{FixturePaymentID}, 900)
+	noError(t, err, "GetBulkPayments")
+	equal(t, 0, len(payments), "payments above height 900")
+
+	_, err = c.GetBulkPayments([]string{"nothex"}, 0)
+	hasCode(t, err, walletrpc.ErrWrongPaymentID, "GetBulkPayments")
+}
+
+func testGetTransfers(t *testing.T, c walletrpc.Client, w *Wallet) {
+	resp, err := c.GetTransfers(walletrpc.GetTransfersRequest{In: true, Out: true, Pool: true})
+	noError(t, err, "GetTransfers")
+	equal(t, 2, len(resp.In), "in transfers")
+	equal(t, 0, len(resp.Out), "out transfers")
+	equal(t, 1, len(resp.Pool), "pool transfers")
+	for _, tr := range resp.In {
+		equal(t, "in", tr.Type, "in transfer type")
+		if tr.PaymentID == FixturePaymentID {
+			equal(t, FixtureNote, tr.Note, "transfer note")
+		}
+	}
+
+	resp, err = c.GetTransfers(walletrpc.GetTransfersRequest{In: true, FilterByHeight: true, MinHeight: 900})
+	noError(t, err, "GetTransfers")
+	if equal(t, 1, len(resp.In), "in transfers above height 900") {
+		equal(t, uint64(950), resp.In[0].Height, "transfer height")
+	}
+}
+
+func testGetTransferByTxID(t *testing.T, c walletrpc.Client, w *Wallet) {
+	resp, err := c.GetTransfers(walletrpc.GetTransfersRequest{In: true})
+	noError(t, err, "GetTransfers")
+	for _, in := range resp.In {
+		tr, err := c.GetTransferByTxID(in.TxID)
+		noError(t, err, "GetTransferByTxID")
+		equal(t, in.TxID, tr.TxID, "txid")
+		equal(t, in.Amount, tr.Amount, "amount")
+		equal(t, in.Note, tr.Note, "note")
+	}
+
+	_, err = c.GetTransferByTxID(strings.Repeat("0", 64))
+	hasCode(t, err, walletrpc.ErrWrongTxID, "GetTransferByTxID")
+	_, err = c.GetTransferByTxID("nothex")
+	hasCode(t, err, walletrpc.ErrWrongTxID, "GetTransferByTxID")
+}
+
+func testIncomingTransfers(t *testing.T, c walletrpc.Client, w *Wallet) {
+	transfers, err := c.IncomingTransfers(walletrpc.TransferAll)
+	noError(t, err, "IncomingTransfers")
+	equal(t, 2, len(transfers), "incoming transfers")
+	transfers, err = c.IncomingTransfers(walletrpc.TransferAvailable)
+	noError(t, err, "IncomingTransfers")
+	equal(t, 2, len(transfers), "available incoming transfers")
+	transfers, err = c.IncomingTransfers(walletrpc.TransferUnavailable)
+	noError(t, err, "IncomingTransfers")
+	equal(t, 0, len(transfers), "unavailable incoming transfers")
+
+	_, err = c.IncomingTransfers(walletrpc.GetTransferType("other"))
+	hasCode(t, err, walletrpc.ErrTransferType, "IncomingTransfers")
+}
+
+func testQueryKey(t *testing.T, c walletrpc.Client, w *Wallet) {
+	for _, kt := range []walletrpc.QueryKeyType{walletrpc.QueryKeyView, walletrpc.QueryKeySpend} {
+		key, err := c.QueryKey(kt)
+		noError(t, err, "QueryKey")
+		equal(t, 64, len(key), "key length")
+	}
+	mnemonic, err := c.QueryKey(walletrpc.QueryKeyMnemonic)
+	noError(t, err, "QueryKey")
+	equal(t, 25, len(strings.Fields(mnemonic)), "mnemonic words")
+}
+
+func testIntegratedAddress(t *testing.T, c walletrpc.Client, w *Wallet) {
+	integrated, err := c.MakeIntegratedAddress(FixturePaymentID)
+	noError(t, err, "MakeIntegratedAddress")
+	equal(t, 106, len(integrated), "integrated address length")
+	pid, addr, err := c.SplitIntegratedAddress(integrated)
+	noError(t, err, "SplitIntegratedAddress")
+	equal(t, FixturePaymentID, pid, "payment id")
+	equal(t, DefaultAddress, addr, "address")
+
+	_, err = c.MakeIntegratedAddress("nothex")
+	hasCode(t, err, walletrpc.ErrWrongPaymentID, "MakeIntegratedAddress")
+	_, _, err = c.SplitIntegratedAddress("4nothing")
+	hasCode(t, err, walletrpc.ErrWrongAddress, "SplitIntegratedAddress")
+}
+
+func testURI(t *testing.T, c walletrpc.Client, w *Wallet) {
+	def := walletrpc.URIDef{
+		Address:       DefaultAddress,
+		Amount:        15e11,
+		PaymentID:     FixturePaymentID,
+		RecipientName: "Alice",
+		TxDescription: "coffee & cake",
+	}
+	uri, err := c.MakeURI(def)
+	noError(t, err, "MakeURI")
+	if !strings.HasPrefix(uri, "monero:"+DefaultAddress) {
+		t.Errorf("MakeURI: unexpected URI %v", uri)
+	}
+	parsed, err := c.ParseURI(uri)
+	noError(t, err, "ParseURI")
+	equal(t, def, *parsed, "parsed URI")
+
+	_, err = c.MakeURI(walletrpc.URIDef{})
+	hasCode(t, err, walletrpc.ErrWrongURI, "MakeURI")
+	_, err = c.ParseURI("bitcoin:" + DefaultAddress)
+	hasCode(t, err, walletrpc.ErrWrongURI, "ParseURI")
+}
+
+func testRescan(t *testing.T, c walletrpc.Client, w *Wallet) {
+	noError(t, c.RescanBlockchain(), "RescanBlockchain")
+	noError(t, c.RescanSpent(), "RescanSpent")
+	sentTimes(t, w, "rescan_blockchain", 1)
+	sentTimes(t, w, "rescan_spent", 1)
+}
+
+func testMining(t *testing.T, c walletrpc.Client, w *Wallet) {
+	noError(t, c.StartMining(1, true, false), "StartMining")
+	noError(t, c.StopMining(), "StopMining")
+	hasCode(t, c.StartMining(0, false, false), walletrpc.ErrUnknown, "StartMining")
+}
+
+func testTxNotes(t *testing.T, c walletrpc.Client, w *Wallet) {
+	resp, err := c.GetTransfers(walletrpc.GetTransfersRequest{In: true})
+	noError(t, err, "GetTransfers")
+	txids := make([]string, 0, len(resp.In))
+	for _, tr := range resp.In {
+		txids = append(txids, tr.TxID)
+	}
+	notes, err := c.GetTxNotes(txids)
+	noError(t, err, "GetTxNotes")
+	equal(t, len(txids), len(notes), "notes")
+
+	want := make([]string, len(txids))
+	for i := range want {
+		want[i] = "note " + string(rune('a'+i))
+	}
+	noError(t, c.SetTxNotes(txids, want), "SetTxNotes")
+	notes, err = c.GetTxNotes(txids)
+	noError(t, err, "GetTxNotes")
+	equal(t, strings.Join(want, ","), strings.Join(notes, ","), "notes after SetTxNotes")
+	tr, err := c.GetTransferByTxID(txids[0])
+	noError(t, err, "GetTransferByTxID")
+	equal(t, want[0], tr.Note, "note after SetTxNotes")
+
+	_, err = c.GetTxNotes([]string{"nothex"})
+	hasCode(t, err, walletrpc.ErrWrongTxID, "GetTxNotes")
+	hasCode(t, c.SetTxNotes([]string{"nothex"}, []string{"x"}), walletrpc.ErrWrongTxID, "SetTxNotes")
+}
+
+func testSignVerify(t *testing.T, c walletrpc.Client, w *Wallet) {
+	sig, err := c.Sign("hello")
+	noError(t, err, "Sign")
+	if !strings.HasPrefix(sig, "SigV1") {
+		t.Errorf("Sign: unexpected signature %v", sig)
+	}
+	good, err := c.Verify("hello", DefaultAddress, sig)
+	noError(t, err, "Verify")
+	equal(t, true, good, "signature of the signed data")
+	good, err = c.Verify("hellO", DefaultAddress, sig)
+	noError(t, err, "Verify")
+	equal(t, false, good, "signature of other data")
+
+	_, err = c.Verify("hello", "", sig)
+	hasCode(t, err, walletrpc.ErrWrongAddress, "Verify")
+}
+
+func testKeyImages(t *testing.T, c walletrpc.Client, w *Wallet) {
+	images, err := c.ExportKeyImages()
+	noError(t, err, "ExportKeyImages")
+	equal(t, 2, len(images), "key images")
+	resp, err := c.ImportKeyImages(images)
+	noError(t, err, "ImportKeyImages")
+	equal(t, uint64(12e12), resp.Unspent, "unspent")
+	equal(t, uint64(0), resp.Spent, "spent")
+	equal(t, uint64(FixtureHeight), resp.Height, "height")
+
+	_, err = c.ImportKeyImages([]walletrpc.SignedKeyImage{{KeyImage: "nothex"}})
+	hasCode(t, err, walletrpc.ErrWrongKeyImage, "ImportKeyImages")
+}
+
+func testAddressBook(t *testing.T, c walletrpc.Client, w *Wallet) {
+	entries, err := c.GetAddressBook(nil)
+	noError(t, err, "GetAddressBook")
+	if len(entries) != 1 {
+		t.Fatalf("GetAddressBook: want 1 entry, got %v", len(entries))
+	}
+	equal(t, "self", entries[0].Description, "description")
+	equal(t, uint64(0), entries[0].Index, "index")
+
+	idx, err := c.AddAddressBook(walletrpc.AddressBookEntry{Address: DefaultAddress, Description: "again"})
+	noError(t, err, "AddAddressBook")
+	equal(t, uint64(1), idx, "index of the new entry")
+	sentTimes(t, w, "add_address_book", 1)
+	entries, err = c.GetAddressBook(nil)
+	noError(t, err, "GetAddressBook")
+	equal(t, 2, len(entries), "entries after AddAddressBook")
+
+	noError(t, c.DeleteAddressBook(0), "DeleteAddressBook")
+	sentTimes(t, w, "delete_address_book", 1)
+	entries, err = c.GetAddressBook([]uint64{0})
+	noError(t, err, "GetAddressBook")
+	if equal(t, 1, len(entries), "entries") {
+		equal(t, "again", entries[0].Description, "description after DeleteAddressBook")
+	}
+
+	_, err = c.GetAddressBook([]uint64{5})
+	hasCode(t, err, walletrpc.ErrWrongIndex, "GetAddressBook")
+	hasCode(t, c.DeleteAddressBook(5), walletrpc.ErrWrongIndex, "DeleteAddressBook")
+	_, err = c.AddAddressBook(walletrpc.AddressBookEntry{})
+	hasCode(t, err, walletrpc.ErrWrongAddress, "AddAddressBook")
+}
+
+func testGetLanguages(t *testing.T, c walletrpc.Client, w *Wallet) {
+	languages, err := c.GetLanguages()
+	noError(t, err, "GetLanguages")
+	found := false
+	for _, l := range languages {
+		found = found || l == "English"
+	}
+	if !found {
+		t.Errorf("GetLanguages: English not in %v", languages)
+	}
+}
+
+func testWalletFiles(t *testing.T, c walletrpc.Client, w *Wallet) {
+	_, err := c.GetAddress()
+	noError(t, err, "GetAddress")
+
+	noError(t, c.StopWallet(), "StopWallet")
+	_, err = c.GetAddress()
+	hasCode(t, err, walletrpc.ErrNotOpen, "GetAddress")
+	_, _, err = c.GetBalance()
+	hasCode(t, err, walletrpc.ErrNotOpen, "GetBalance")
+
+	err = c.OpenWallet(FixtureWalletFile, "wrong")
+	if errors.Is(err, walletrpc.ErrWalletSelection) {
+		t.Skip("the client does not open wallets")
+	}
+	hasCode(t, err, walletrpc.ErrInvalidPassword, "OpenWallet")
+	noError(t, c.OpenWallet(FixtureWalletFile, FixturePassword), "OpenWallet")
+	addr, err := c.GetAddress()
+	noError(t, err, "GetAddress")
+	equal(t, DefaultAddress, addr, "address")
+
+	noError(t, c.CreateWallet("new", "secret", "English"), "CreateWallet")
+	sentTimes(t, w, "create_wallet", 1)
+	hasCode(t, c.CreateWallet("new", "secret", "English"), walletrpc.ErrWalletAlreadyExists, "CreateWallet")
+	noError(t, c.OpenWallet("new", "secret"), "OpenWallet")
+}
+
+func testAccounts(t *testing.T, c walletrpc.Client, w *Wallet) {
+	resp, err := c.GetAccounts("")
+	noError(t, err, "GetAccounts")
+	if len(resp.SubaddressAccounts) != 1 {
+		t.Fatalf("GetAccounts: want 1 account, got %v", len(resp.SubaddressAccounts))
+	}
+	primary := resp.SubaddressAccounts[0]
+	equal(t, uint64(0), primary.AccountIndex, "account index")
+	equal(t, DefaultAddress, primary.BaseAddress, "base address")
+	equal(t, uint64(12e12), primary.Balance, "account balance")
+	equal(t, uint64(12e12), primary.UnlockedBalance, "account unlocked balance")
+	equal(t, uint64(12e12), resp.TotalBalance, "total balance")
+	equal(t, uint64(12e12), resp.TotalUnlockedBalance, "total unlocked balance")
+
+	index, address, err := c.CreateAccount("savings")
+	noError(t, err, "CreateAccount")
+	equal(t, uint64(1), index, "index of the new account")
+	equal(t, 95, len(address), "address length")
+	sentTimes(t, w, "create_account", 1)
+
+	noError(t, c.LabelAccount(0, "main"), "LabelAccount")
+	resp, err = c.GetAccounts("")
+	noError(t, err, "GetAccounts")
+	if !equal(t, 2, len(resp.SubaddressAccounts), "accounts after CreateAccount") {
+		return
+	}
+	equal(t, "main", resp.SubaddressAccounts[0].Label, "label after LabelAccount")
+	acc := resp.SubaddressAccounts[1]
+	equal(t, uint64(1), acc.AccountIndex, "account index")
+	equal(t, address, acc.BaseAddress, "base address")
+	equal(t, "savings", acc.Label, "label")
+	equal(t, uint64(0), acc.Balance, "balance of the new account")
+	equal(t, uint64(12e12), resp.TotalBalance, "total balance")
+
+	hasCode(t, c.LabelAccount(5, "none"), walletrpc.ErrAccountIndexOutOfBounds, "LabelAccount")
+}
+
+func testAccountTags(t *testing.T, c walletrpc.Client, w *Wallet) {
+	_, _, err := c.CreateAccount("")
+	noError(t, err, "CreateAccount")
+	_, _, err = c.CreateAccount("")
+	noError(t, err, "CreateAccount")
+	tags, err := c.GetAccountTags()
+	noError(t, err, "GetAccountTags")
+	equal(t, 0, len(tags), "tags")
+
+	noError(t, c.TagAccounts("cold", []uint64{1, 2}), "TagAccounts")
+	noError(t, c.SetAccountTagDescription("cold", "cold storage"), "SetAccountTagDescription")
+	tags, err = c.GetAccountTags()
+	noError(t, err, "GetAccountTags")
+	if !equal(t, 1, len(tags), "tags after TagAccounts") {
+		return
+	}
+	equal(t, "cold", tags[0].Tag, "tag")
+	equal(t, "cold storage", tags[0].Label, "tag description")
+	equal(t, "[1 2]", fmt.Sprint(tags[0].Accounts), "tagged accounts")
+
+	resp, err := c.GetAccounts("cold")
+	noError(t, err, "GetAccounts")
+	if equal(t, 2, len(resp.SubaddressAccounts), "accounts with the tag") {
+		equal(t, "cold", resp.SubaddressAccounts[0].Tag, "account tag")
+		equal(t, uint64(0), resp.TotalBalance, "total balance of the tagged accounts")
+	}
+
+	noError(t, c.UntagAccounts([]uint64{1}), "UntagAccounts")
+	resp, err = c.GetAccounts("cold")
+	noError(t, err, "GetAccounts")
+	if equal(t, 1, len(resp.SubaddressAccounts), "accounts with the tag after UntagAccounts") {
+		equal(t, uint64(2), resp.SubaddressAccounts[0].AccountIndex, "account index")
+	}
+
+	_, err = c.GetAccounts("unknown")
+	hasCode(t, err, walletrpc.ErrUnknown, "GetAccounts")
+	hasCode(t, c.SetAccountTagDescription("unknown", "x"), walletrpc.ErrUnknown, "SetAccountTagDescription")
+	hasCode(t, c.TagAccounts("cold", []uint64{7}), walletrpc.ErrUnknown, "TagAccounts")
+}
+
+func testSubaddresses(t *testing.T, c walletrpc.Client, w *Wallet) {
+	resp, err := c.GetAddresses(0, nil)
+	noError(t, err, "GetAddresses")
+	equal(t, DefaultAddress, resp.Address, "base address")
+	if equal(t, 1, len(resp.Addresses), "addresses") {
+		equal(t, DefaultAddress, resp.Addresses[0].Address, "address")
+		equal(t, uint64(0), resp.Addresses[0].AddressIndex, "address index")
+	}
+
+	created, err := c.CreateAddress(0, "deposit", 3)
+	noError(t, err, "CreateAddress")
+	sentTimes(t, w, "create_address", 1)
+	if !equal(t, 3, len(created.Addresses), "created addresses") || !equal(t, 3, len(created.AddressIndices), "created address indices") {
+		return
+	}
+	equal(t, created.Addresses[0], created.Address, "first created address")
+	equal(t, uint64(1), created.AddressIndex, "first created address index")
+	equal(t, "[1 2 3]", fmt.Sprint(created.AddressIndices), "created address indices")
+
+	one, err := c.CreateAddress(0, "", 0)
+	noError(t, err, "CreateAddress")
+	equal(t, uint64(4), one.AddressIndex, "index of a single created address")
+	equal(t, 95, len(one.Address), "address length")
+
+	noError(t, c.LabelAddress(walletrpc.SubaddressIndex{Major: 0, Minor: 2}, "customer 2"), "LabelAddress")
+	w.MarkAddressUsed(walletrpc.SubaddressIndex{Major: 0, Minor: 3})
+	resp, err = c.GetAddresses(0, nil)
+	noError(t, err, "GetAddresses")
+	if !equal(t, 5, len(resp.Addresses), "addresses after CreateAddress") {
+		return
+	}
+	for i, sa := range resp.Addresses {
+		equal(t, uint64(i), sa.AddressIndex, "address index")
+	}
+	equal(t, "deposit", resp.Addresses[1].Label, "label")
+	equal(t, "customer 2", resp.Addresses[2].Label, "label after LabelAddress")
+	equal(t, false, resp.Addresses[2].Used, "used")
+	equal(t, true, resp.Addresses[3].Used, "used")
+
+	resp, err = c.GetAddresses(0, []uint64{3})
+	noError(t, err, "GetAddresses")
+	if equal(t, 1, len(resp.Addresses), "selected addresses") {
+		equal(t, created.Addresses[2], resp.Addresses[0].Address, "selected address")
+	}
+
+	index, err := c.GetAddressIndex(created.Addresses[1])
+	noError(t, err, "GetAddressIndex")
+	equal(t, walletrpc.SubaddressIndex{Major: 0, Minor: 2}, index, "index")
+
+	// subaddresses of another account
+	account, _, err := c.CreateAccount("other")
+	noError(t, err, "CreateAccount")
+	more, err := c.CreateAddress(account, "", 1)
+	noError(t, err, "CreateAddress")
+	equal(t, uint64(1), more.AddressIndex, "index in the new account")
+	index, err = c.GetAddressIndex(more.Address)
+	noError(t, err, "GetAddressIndex")
+	equal(t, walletrpc.SubaddressIndex{Major: account, Minor: 1}, index, "index")
+	resp, err = c.GetAddresses(account, nil)
+	noError(t, err, "GetAddresses")
+	equal(t, 2, len(resp.Addresses), "addresses of the new account")
+	addr, err := c.GetAddress()
+	noError(t, err, "GetAddress")
+	equal(t, DefaultAddress, addr, "address")
+
+	_, err = c.GetAddresses(9, nil)
+	hasCode(t, err, walletrpc.ErrAccountIndexOutOfBounds, "GetAddresses")
+	_, err = c.GetAddresses(0, []uint64{9})
+	hasCode(t, err, walletrpc.ErrAddressIndexOutOfBounds, "GetAddresses")
+	_, err = c.CreateAddress(9, "", 1)
+	hasCode(t, err, walletrpc.ErrAccountIndexOutOfBounds, "CreateAddress")
+	hasCode(t, c.LabelAddress(walletrpc.SubaddressIndex{Major: 0, Minor: 9}, "x"), walletrpc.ErrAddressIndexOutOfBounds, "LabelAddress")
+	_, err = c.GetAddressIndex("nothing")
+	hasCode(t, err, walletrpc.ErrWrongAddress, "GetAddressIndex")
+	_, err = c.GetAddressIndex("4" + strings.Repeat("1", 94))
+	hasCode(t, err, walletrpc.ErrWrongAddress, "GetAddressIndex")
+}
+
+func testCall(t *testing.T, c walletrpc.Client, w *Wallet) {
+	var res struct {
+		Height uint64 `json:"height"`
+	}
+	noError(t, c.Call("getheight", nil, &res), "Call")
+	equal(t, uint64(FixtureHeight), res.Height, "height")
+
+	raw, err := c.CallRaw("getaddress", nil)
+	noError(t, err, "CallRaw")
+	var addr struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(raw, &addr); err != nil {
+		t.Fatalf("CallRaw: invalid result %s: %v", raw, err)
+	}
+	equal(t, DefaultAddress, addr.Address, "address")
+
+	// a state-changing call made through Call must be visible afterwards
+	balance, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	var tr walletrpc.TransferResponse
+	noError(t, c.Call("transfer", walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+	}, &tr), "Call")
+	sentTimes(t, w, "transfer", 1)
+	after, _, err := c.GetBalance()
+	noError(t, err, "GetBalance")
+	equal(t, balance-1e12-tr.Fee, after, "balance after a transfer made with Call")
+
+	_, err = c.CallRaw("no_such_method", nil)
+	hasCode(t, err, errMethodNotFound, "CallRaw")
+}
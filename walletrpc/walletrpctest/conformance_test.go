@@ -0,0 +1,58 @@
+package walletrpctest
+
+import (
+	"testing"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+func TestConformanceWallet(t *testing.T) {
+	RunConformance(t, func(t *testing.T, w *Wallet) walletrpc.Client {
+		return w
+	})
+}
+
+func TestConformanceClient(t *testing.T) {
+	RunConformance(t, func(t *testing.T, w *Wallet) walletrpc.Client {
+		return walletrpc.New(walletrpc.Config{
+			Address:   "http://walletrpctest/json_rpc",
+			Transport: w,
+			Retry:     &walletrpc.RetryPolicy{MaxAttempts: 3},
+		})
+	})
+}
+
+func TestConformanceCachedClient(t *testing.T) {
+	RunConformance(t, func(t *testing.T, w *Wallet) walletrpc.Client {
+		return walletrpc.NewCachedClient(w, walletrpc.CacheConfig{})
+	})
+}
+
+func TestConformancePool(t *testing.T) {
+	RunConformance(t, func(t *testing.T, w *Wallet) walletrpc.Client {
+		return walletrpc.NewPool(walletrpc.PoolConfig{
+			Endpoints: []walletrpc.Config{
+				{Address: "http://a.walletrpctest/json_rpc", Transport: w},
+				{Address: "http://b.walletrpctest/json_rpc", Transport: w},
+			},
+		})
+	})
+}
+
+// serverClient closes its Server with the client.
+type serverClient struct {
+	walletrpc.Client
+	sv *Server
+}
+
+func (c *serverClient) Close() error {
+	c.sv.Close()
+	return nil
+}
+
+func TestConformanceServer(t *testing.T) {
+	RunConformance(t, func(t *testing.T, w *Wallet) walletrpc.Client {
+		sv := NewServer(ServerConfig{Wallet: w, Username: "user", Password: "pass", TLS: true})
+		return &serverClient{walletrpc.New(sv.Config()), sv}
+	})
+}
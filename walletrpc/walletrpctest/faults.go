@@ -0,0 +1,263 @@
+package walletrpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+// ErrConnectionDropped is returned by a FaultTransport when it drops the
+// connection of a request.
+var ErrConnectionDropped = errors.New("walletrpctest: connection dropped after the request was sent")
+
+// Fault is a failure injected by a FaultTransport. Latency can be combined
+// with any other field.
+type Fault struct {
+	// Latency delays the request.
+	Latency time.Duration
+	// Drop sends the request, then drops the connection before the
+	// response is read. The wallet runs the call.
+	Drop bool
+	// Truncate cuts the response body in half. The wallet runs the call.
+	Truncate bool
+	// Malformed replaces the response body with invalid JSON. The wallet
+	// runs the call.
+	Malformed bool
+	// HTTPStatus answers with this HTTP status (401, 500...) without
+	// sending the request.
+	HTTPStatus int
+	// WalletError answers with this error without sending the request,
+	// like a wallet rejecting the call (ErrDaemonIsBusy...).
+	WalletError *walletrpc.WalletError
+}
+
+// FaultRule tells which requests get a Fault.
+type FaultRule struct {
+	// Method is the method the rule applies to. An empty method matches
+	// every request.
+	Method string
+	// Probability is the chance, between 0 and 1, that a matching request
+	// fails. Zero means every matching request fails.
+	Probability float64
+	// Times is the number of faults injected by the rule. Zero means no
+	// limit.
+	Times int
+	// Fault is the failure injected.
+	Fault Fault
+}
+
+type faultRule struct {
+	FaultRule
+	injected int
+}
+
+// FaultTransport is an http.RoundTripper, for walletrpc.Config.Transport,
+// that injects failures in the requests sent to another transport, to test
+// how code behaves when monero-wallet-rpc or the network misbehaves.
+//
+// Rules are checked in the order they were added, and the first one that
+// fires applies. A batch matches the rules of any of its methods.
+//
+// A dropped connection fails with ErrConnectionDropped, which is not a dial
+// error, so a walletrpc client treats the request as possibly sent and does
+// not retry non-idempotent calls.
+type FaultTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	rules    []*faultRule
+	rand     *rand.Rand
+	injected map[string]int
+}
+
+// NewFaultTransport returns a FaultTransport sending the requests to next
+// (http.DefaultTransport when nil). seed makes the probabilistic rules
+// reproducible.
+func NewFaultTransport(next http.RoundTripper, seed int64) *FaultTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &FaultTransport{
+		next:     next,
+		rand:     rand.New(rand.NewSource(seed)),
+		injected: make(map[string]int),
+	}
+}
+
+// Add adds a rule.
+func (f *FaultTransport) Add(rule FaultRule) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &faultRule{FaultRule: rule})
+}
+
+// Reset removes every rule.
+func (f *FaultTransport) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = nil
+}
+
+// Injected returns how many faults were injected in requests calling
+// method, or in every request when method is empty.
+func (f *FaultTransport) Injected(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if method == "" {
+		n := 0
+		for _, v := range f.injected {
+			n += v
+		}
+		return n
+	}
+	return f.injected[method]
+}
+
+// fault returns the fault for a request calling methods, if any.
+func (f *FaultTransport) fault(methods []string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range f.rules {
+		if r.Times > 0 && r.injected >= r.Times {
+			continue
+		}
+		method, ok := r.match(methods)
+		if !ok {
+			continue
+		}
+		if r.Probability > 0 && f.rand.Float64() >= r.Probability {
+			continue
+		}
+		r.injected++
+		f.injected[method]++
+		fault := r.Fault
+		return &fault
+	}
+	return nil
+}
+
+func (r *faultRule) match(methods []string) (string, bool) {
+	for _, m := range methods {
+		if r.Method == "" || r.Method == m {
+			return m, true
+		}
+	}
+	return "", false
+}
+
+// RoundTrip sends the request, injecting the fault of the first rule that
+// fires.
+func (f *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	reqs := parseRequests(body)
+	methods := make([]string, 0, len(reqs))
+	for _, r := range reqs {
+		methods = append(methods, r.Method)
+	}
+	fault := f.fault(methods)
+	if fault == nil {
+		return f.send(req, body)
+	}
+
+	if fault.Latency > 0 {
+		t := time.NewTimer(fault.Latency)
+		select {
+		case <-req.Context().Done():
+			t.Stop()
+			return nil, req.Context().Err()
+		case <-t.C:
+		}
+	}
+	switch {
+	case fault.HTTPStatus != 0:
+		return newResponse(req, fault.HTTPStatus, "text/plain", []byte(http.StatusText(fault.HTTPStatus))), nil
+	case fault.WalletError != nil:
+		resps := make([]*response, 0, len(reqs))
+		for _, r := range reqs {
+			resps = append(resps, &response{Version: "2.0", ID: r.ID, Error: fault.WalletError})
+		}
+		var v interface{} = resps
+		if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) && len(resps) == 1 {
+			v = resps[0]
+		}
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return newResponse(req, http.StatusOK, "application/json", buf), nil
+	}
+
+	resp, err := f.send(req, body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case fault.Drop:
+		resp.Body.Close()
+		return nil, ErrConnectionDropped
+	case fault.Truncate, fault.Malformed:
+		rbody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if fault.Truncate {
+			rbody = rbody[:len(rbody)/2]
+		} else {
+			rbody = []byte(`{"jsonrpc":"2.0","result":{,}}`)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(rbody))
+		resp.ContentLength = int64(len(rbody))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(rbody)))
+	}
+	return resp, nil
+}
+
+// send forwards the request to the next transport.
+func (f *FaultTransport) send(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	return f.next.RoundTrip(out)
+}
+
+// parseRequests decodes a single or batch JSON-RPC request body.
+func parseRequests(body []byte) []request {
+	var reqs []request
+	if err := json.Unmarshal(body, &reqs); err != nil {
+		var req request
+		json.Unmarshal(body, &req)
+		reqs = []request{req}
+	}
+	return reqs
+}
+
+func newResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,117 @@
+package walletrpctest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFaultClient(w *Wallet, ft *FaultTransport) walletrpc.ClientContext {
+	return walletrpc.NewClientContext(walletrpc.Config{
+		Address:   "http://walletrpctest/json_rpc",
+		Transport: ft,
+		Retry: &walletrpc.RetryPolicy{
+			MaxAttempts:    4,
+			InitialBackoff: time.Millisecond,
+		},
+	})
+}
+
+func outgoing(w *Wallet) int {
+	n := 0
+	for _, tr := range w.Transfers() {
+		if tr.Type == "out" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFaultTransport(t *testing.T) {
+	w := NewWallet()
+	w.AddIncomingTransfer(walletrpc.Transfer{Amount: 5e12})
+	ft := NewFaultTransport(w, 1)
+	cl := newFaultClient(w, ft)
+	req := walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e11, Address: DefaultAddress}},
+	}
+
+	// read-only methods are retried
+	ft.Add(FaultRule{Method: "getheight", Times: 1, Fault: Fault{Drop: true}})
+	ft.Add(FaultRule{Method: "getheight", Times: 1, Fault: Fault{HTTPStatus: http.StatusInternalServerError}})
+	ft.Add(FaultRule{Method: "getheight", Times: 1, Fault: Fault{WalletError: &walletrpc.WalletError{Code: walletrpc.ErrDaemonIsBusy}}})
+	height, err := cl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), height)
+	assert.Equal(t, 3, ft.Injected("getheight"))
+	assert.Equal(t, 2, w.Calls("getheight"))
+
+	ft.Add(FaultRule{Method: "getaddress", Fault: Fault{HTTPStatus: http.StatusUnauthorized}})
+	_, err = cl.GetAddress()
+	var herr *walletrpc.HTTPError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.Equal(t, http.StatusUnauthorized, herr.StatusCode)
+	}
+
+	// transfers that may have reached the wallet are never sent again
+	for _, f := range []Fault{{Drop: true}, {Truncate: true}, {Malformed: true}} {
+		ft.Reset()
+		ft.Add(FaultRule{Method: "transfer", Times: 1, Fault: f})
+		calls := w.Calls("transfer")
+		_, err = cl.Transfer(req)
+		assert.Error(t, err)
+		assert.Equal(t, calls+1, w.Calls("transfer"))
+	}
+	assert.Equal(t, 3, outgoing(w))
+
+	ft.Reset()
+	ft.Add(FaultRule{Method: "transfer", Times: 1, Fault: Fault{WalletError: &walletrpc.WalletError{Code: walletrpc.ErrDaemonIsBusy}}})
+	_, err = cl.Transfer(req)
+	assert.True(t, errors.Is(err, walletrpc.ErrDaemonIsBusy))
+	assert.Equal(t, 3, outgoing(w))
+
+	ft.Reset()
+	ft.Add(FaultRule{Fault: Fault{Latency: time.Second}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err = cl.GetBalanceContext(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+}
+
+func TestFaultTransportChaos(t *testing.T) {
+	w := NewWallet()
+	w.AddIncomingTransfer(walletrpc.Transfer{Amount: 5e12})
+	ft := NewFaultTransport(w, 42)
+	cl := newFaultClient(w, ft)
+	busy := &walletrpc.WalletError{Code: walletrpc.ErrDaemonIsBusy, Message: "daemon is busy"}
+	ft.Add(FaultRule{Probability: 0.1, Fault: Fault{Drop: true}})
+	ft.Add(FaultRule{Probability: 0.1, Fault: Fault{Truncate: true}})
+	ft.Add(FaultRule{Probability: 0.1, Fault: Fault{Malformed: true}})
+	ft.Add(FaultRule{Probability: 0.1, Fault: Fault{HTTPStatus: http.StatusInternalServerError}})
+	ft.Add(FaultRule{Probability: 0.1, Fault: Fault{WalletError: busy}})
+	ft.Add(FaultRule{Probability: 0.2, Fault: Fault{Latency: time.Millisecond}})
+
+	req := walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e10, Address: DefaultAddress}},
+	}
+	for i := 0; i < 100; i++ {
+		before := outgoing(w)
+		_, err := cl.Transfer(req)
+		after := outgoing(w)
+		if err == nil {
+			assert.Equal(t, before+1, after)
+		} else {
+			assert.True(t, after-before <= 1, "transfer sent %v times", after-before)
+		}
+		cl.GetBalance()
+	}
+	assert.True(t, ft.Injected("transfer") > 0)
+	assert.True(t, ft.Injected("getbalance") > 0)
+	assert.True(t, w.Calls("getbalance") >= 100)
+}
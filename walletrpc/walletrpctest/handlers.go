@@ -0,0 +1,972 @@
+package walletrpctest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+type handler func(w *Wallet, params json.RawMessage) (interface{}, *walletrpc.WalletError)
+
+// handlers serve each monero-wallet-rpc method. They run with w.mu held.
+var handlers = map[string]handler{
+	"getbalance":               (*Wallet).getBalance,
+	"getaddress":               (*Wallet).getAddress,
+	"get_address":              (*Wallet).getAddress,
+	"getheight":                (*Wallet).getHeight,
+	"transfer":                 (*Wallet).transfer,
+	"transfer_split":           (*Wallet).transferSplit,
+	"sweep_dust":               (*Wallet).sweepDust,
+	"sweep_all":                (*Wallet).sweepAll,
+	"store":                    (*Wallet).noop,
+	"get_payments":             (*Wallet).getPayments,
+	"get_bulk_payments":        (*Wallet).getBulkPayments,
+	"get_transfers":            (*Wallet).getTransfers,
+	"get_transfer_by_txid":     (*Wallet).getTransferByTxID,
+	"incoming_transfers":       (*Wallet).incomingTransfers,
+	"query_key":                (*Wallet).queryKey,
+	"make_integrated_address":  (*Wallet).makeIntegratedAddress,
+	"split_integrated_address": (*Wallet).splitIntegratedAddress,
+	"stop_wallet":              (*Wallet).stopWallet,
+	"make_uri":                 (*Wallet).makeURI,
+	"parse_uri":                (*Wallet).parseURI,
+	"rescan_blockchain":        (*Wallet).noop,
+	"set_tx_notes":             (*Wallet).setTxNotes,
+	"get_tx_notes":             (*Wallet).getTxNotes,
+	"sign":                     (*Wallet).sign,
+	"verify":                   (*Wallet).verify,
+	"export_key_images":        (*Wallet).exportKeyImages,
+	"import_key_images":        (*Wallet).importKeyImages,
+	"get_address_book":         (*Wallet).getAddressBook,
+	"add_address_book":         (*Wallet).addAddressBook,
+	"delete_address_book":      (*Wallet).deleteAddressBook,
+	"rescan_spent":             (*Wallet).noop,
+	"start_mining":             (*Wallet).startMining,
+	"stop_mining":              (*Wallet).noop,
+	"get_languages":            (*Wallet).getLanguages,
+	"create_wallet":            (*Wallet).createWallet,
+	"open_wallet":              (*Wallet).openWallet,
+
+	"create_account":              (*Wallet).createAccount,
+	"get_accounts":                (*Wallet).getAccounts,
+	"label_account":               (*Wallet).labelAccount,
+	"get_account_tags":            (*Wallet).getAccountTags,
+	"tag_accounts":                (*Wallet).tagAccounts,
+	"untag_accounts":              (*Wallet).untagAccounts,
+	"set_account_tag_description": (*Wallet).setAccountTagDescription,
+
+	"create_address":    (*Wallet).createAddress,
+	"label_address":     (*Wallet).labelAddress,
+	"get_address_index": (*Wallet).getAddressIndex,
+}
+
+// walletless are the methods that work without an open wallet.
+var walletless = map[string]bool{
+	"get_languages": true,
+	"create_wallet": true,
+	"open_wallet":   true,
+}
+
+func isHex(s string, lengths ...int) bool {
+	if _, err := hex.DecodeString(s); err != nil {
+		return false
+	}
+	for _, l := range lengths {
+		if len(s) == l {
+			return true
+		}
+	}
+	return false
+}
+
+func validPaymentID(pid string) bool {
+	return pid == "" || isHex(pid, 16, 64)
+}
+
+func (w *Wallet) noop(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	return nil, nil
+}
+
+func (w *Wallet) getBalance(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	return walletrpc.H{"balance": w.balance, "unlocked_balance": w.unlocked}, nil
+}
+
+// getAddress serves both getaddress and get_address.
+func (w *Wallet) getAddress(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		AccountIndex   uint64   `json:"account_index"`
+		AddressIndexes []uint64 `json:"address_index"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if err := w.checkIndex(req.AccountIndex, 0); err != nil {
+		return nil, err
+	}
+	indexes := req.AddressIndexes
+	if len(indexes) == 0 {
+		for i := range w.accounts[req.AccountIndex].addresses {
+			indexes = append(indexes, uint64(i))
+		}
+	}
+	resp := &walletrpc.GetAddressResponse{Address: w.addressAt(req.AccountIndex, 0)}
+	for _, idx := range indexes {
+		if err := w.checkIndex(req.AccountIndex, idx); err != nil {
+			return nil, err
+		}
+		sa := w.accounts[req.AccountIndex].addresses[idx]
+		resp.Addresses = append(resp.Addresses, walletrpc.Subaddress{
+			Address:      w.addressAt(req.AccountIndex, idx),
+			Label:        sa.label,
+			AddressIndex: idx,
+			Used:         sa.used,
+		})
+	}
+	return resp, nil
+}
+
+func (w *Wallet) getHeight(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	return walletrpc.H{"height": w.height}, nil
+}
+
+// spend debits amount plus the fee and records an out transfer.
+func (w *Wallet) spend(dests []walletrpc.Destination, paymentid string, relay bool) (*walletrpc.Transfer, *walletrpc.WalletError) {
+	if len(dests) == 0 {
+		return nil, walletError(walletrpc.ErrZeroDestination, "No destinations for this transfer")
+	}
+	if !validPaymentID(paymentid) {
+		return nil, walletError(walletrpc.ErrWrongPaymentID, "Payment id has invalid format: \""+paymentid+"\", expected 16 or 64 character string")
+	}
+	var amount uint64
+	for _, d := range dests {
+		if d.Address == "" {
+			return nil, walletError(walletrpc.ErrWrongAddress, "WALLET_RPC_ERROR_CODE_WRONG_ADDRESS: "+d.Address)
+		}
+		if d.Amount == 0 {
+			return nil, walletError(walletrpc.ErrZeroAmount, "amount is zero")
+		}
+		amount += d.Amount
+	}
+	total := amount + w.fee
+	if total > w.balance {
+		return nil, walletError(walletrpc.ErrNotEnoughMoney, "not enough money")
+	}
+	if total > w.unlocked {
+		return nil, walletError(walletrpc.ErrNotEnoughUnlockedMoney, "not enough unlocked money")
+	}
+	t := &walletrpc.Transfer{
+		TxID:         w.nextHash("tx"),
+		PaymentID:    paymentid,
+		Height:       w.height,
+		Timestamp:    uint64(time.Now().Unix()),
+		Amount:       amount,
+		Fee:          w.fee,
+		Destinations: dests,
+		Type:         "out",
+	}
+	if relay {
+		w.balance -= total
+		w.unlocked -= total
+		w.transfers = append(w.transfers, *t)
+	}
+	return t, nil
+}
+
+func (w *Wallet) transfer(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.TransferRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	t, err := w.spend(req.Destinations, req.PaymentID, !req.DoNotRelay)
+	if err != nil {
+		return nil, err
+	}
+	resp := &walletrpc.TransferResponse{
+		Fee:    t.Fee,
+		TxHash: t.TxID,
+	}
+	if req.GetTxKey {
+		resp.TxKey = w.hash("tx_key", t.TxID)
+	}
+	if req.GetTxHex {
+		resp.TxBlob = w.hash("tx_blob", t.TxID)
+	}
+	return resp, nil
+}
+
+func (w *Wallet) transferSplit(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.TransferRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	t, err := w.spend(req.Destinations, req.PaymentID, !req.DoNotRelay)
+	if err != nil {
+		return nil, err
+	}
+	resp := &walletrpc.TransferSplitResponse{
+		FeeList:    []uint64{t.Fee},
+		TxHashList: []string{t.TxID},
+		AmountList: []uint64{t.Amount},
+	}
+	if req.GetTxKey {
+		resp.TxKeyList = []string{w.hash("tx_key", t.TxID)}
+	}
+	if req.GetTxHex {
+		resp.TxBlobList = []string{w.hash("tx_blob", t.TxID)}
+	}
+	return resp, nil
+}
+
+func (w *Wallet) sweepDust(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	// the fake wallet has no dust
+	return walletrpc.H{}, nil
+}
+
+func (w *Wallet) sweepAll(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.SweepAllRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if w.unlocked <= w.fee {
+		return nil, walletError(walletrpc.ErrTxNotPossible, "No unlocked balance in the specified account")
+	}
+	dests := []walletrpc.Destination{{Amount: w.unlocked - w.fee, Address: req.Address}}
+	t, err := w.spend(dests, req.PaymentID, !req.DoNotRelay)
+	if err != nil {
+		return nil, err
+	}
+	resp := &walletrpc.SweepAllResponse{
+		TxHashList: []string{t.TxID},
+	}
+	if req.GetTxKeys {
+		resp.TxKeyList = []string{w.hash("tx_key", t.TxID)}
+	}
+	if req.GetTxHex {
+		resp.TxBlobList = []string{w.hash("tx_blob", t.TxID)}
+	}
+	return resp, nil
+}
+
+// payments returns the payments with one of the payment ids, above
+// minheight.
+func (w *Wallet) payments(paymentids []string, minheight uint64) []walletrpc.Payment {
+	var payments []walletrpc.Payment
+	for _, t := range w.transfers {
+		if t.Type != "in" || t.PaymentID == "" || t.Height <= minheight {
+			continue
+		}
+		for _, pid := range paymentids {
+			if pid == t.PaymentID {
+				payments = append(payments, walletrpc.Payment{
+					PaymentID:   t.PaymentID,
+					TxHash:      t.TxID,
+					Amount:      t.Amount,
+					BlockHeight: t.Height,
+				})
+				break
+			}
+		}
+	}
+	return payments
+}
+
+func (w *Wallet) getPayments(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		PaymentID string `json:"payment_id"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.PaymentID == "" || !validPaymentID(req.PaymentID) {
+		return nil, walletError(walletrpc.ErrWrongPaymentID, "Payment ID has invalid format")
+	}
+	return walletrpc.H{"payments": w.payments([]string{req.PaymentID}, 0)}, nil
+}
+
+func (w *Wallet) getBulkPayments(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		PaymentIDs     []string `json:"payment_ids"`
+		MinBlockHeight uint64   `json:"min_block_height"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	for _, pid := range req.PaymentIDs {
+		if pid == "" || !validPaymentID(pid) {
+			return nil, walletError(walletrpc.ErrWrongPaymentID, "Payment ID has invalid format: "+pid)
+		}
+	}
+	return walletrpc.H{"payments": w.payments(req.PaymentIDs, req.MinBlockHeight)}, nil
+}
+
+// withNote returns t with the note set by SetTxNotes.
+func (w *Wallet) withNote(t walletrpc.Transfer) walletrpc.Transfer {
+	t.Note = w.notes[t.TxID]
+	return t
+}
+
+func (w *Wallet) getTransfers(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.GetTransfersRequest
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	resp := &walletrpc.GetTransfersResponse{}
+	for _, t := range w.transfers {
+		if req.FilterByHeight && t.Type != "pool" && (t.Height <= req.MinHeight || req.MaxHeight > 0 && t.Height > req.MaxHeight) {
+			continue
+		}
+		t = w.withNote(t)
+		switch {
+		case t.Type == "in" && req.In:
+			resp.In = append(resp.In, t)
+		case t.Type == "out" && req.Out:
+			resp.Out = append(resp.Out, t)
+		case t.Type == "pending" && req.Pending:
+			resp.Pending = append(resp.Pending, t)
+		case t.Type == "failed" && req.Failed:
+			resp.Failed = append(resp.Failed, t)
+		case t.Type == "pool" && req.Pool:
+			resp.Pool = append(resp.Pool, t)
+		}
+	}
+	return resp, nil
+}
+
+func (w *Wallet) getTransferByTxID(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		TxID string `json:"txid"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if !isHex(req.TxID, 64) {
+		return nil, walletError(walletrpc.ErrWrongTxID, "Transaction ID has invalid format")
+	}
+	for _, t := range w.transfers {
+		if t.TxID == req.TxID {
+			return walletrpc.H{"transfer": w.withNote(t)}, nil
+		}
+	}
+	return nil, walletError(walletrpc.ErrWrongTxID, "Transaction not found.")
+}
+
+func (w *Wallet) incomingTransfers(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		TransferType walletrpc.GetTransferType `json:"transfer_type"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	var transfers []walletrpc.IncTransfer
+	for _, t := range w.incoming {
+		switch req.TransferType {
+		case walletrpc.TransferAll:
+		case walletrpc.TransferAvailable:
+			if t.Spent {
+				continue
+			}
+		case walletrpc.TransferUnavailable:
+			if !t.Spent {
+				continue
+			}
+		default:
+			return nil, walletError(walletrpc.ErrTransferType, "Transfer type must be one of: all, available, or unavailable")
+		}
+		transfers = append(transfers, t)
+	}
+	return walletrpc.H{"transfers": transfers}, nil
+}
+
+func (w *Wallet) queryKey(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		KeyType walletrpc.QueryKeyType `json:"key_type"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	key, ok := w.keys[req.KeyType]
+	if !ok {
+		return nil, walletError(walletrpc.ErrUnknown, "key_type "+string(req.KeyType)+" not found")
+	}
+	return walletrpc.H{"key": key}, nil
+}
+
+func (w *Wallet) makeIntegratedAddress(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		PaymentID string `json:"payment_id"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	pid := req.PaymentID
+	if pid == "" {
+		pid = w.nextHash("payment_id")[:16]
+	} else if !isHex(pid, 16) {
+		return nil, walletError(walletrpc.ErrWrongPaymentID, "Invalid payment ID")
+	}
+	// integrated addresses are 106 characters long
+	addr := w.address[:1] + (w.hash("integrated", w.address, pid) + w.hash(pid, w.address))[:105]
+	w.integrated[addr] = [2]string{w.address, pid}
+	return walletrpc.H{"integrated_address": addr, "payment_id": pid}, nil
+}
+
+func (w *Wallet) splitIntegratedAddress(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		IntegratedAddress string `json:"integrated_address"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	v, ok := w.integrated[req.IntegratedAddress]
+	if !ok {
+		return nil, walletError(walletrpc.ErrWrongAddress, "Invalid address")
+	}
+	return walletrpc.H{"standard_address": v[0], "payment_id": v[1], "is_subaddress": false}, nil
+}
+
+func (w *Wallet) stopWallet(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	w.open = false
+	return nil, nil
+}
+
+func (w *Wallet) makeURI(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.URIDef
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Address == "" {
+		return nil, walletError(walletrpc.ErrWrongURI, "Cannot make URI from supplied parameters: Failed to parse address")
+	}
+	if !validPaymentID(req.PaymentID) {
+		return nil, walletError(walletrpc.ErrWrongURI, "Cannot make URI from supplied parameters: Invalid payment ID")
+	}
+	q := make([]string, 0, 4)
+	if req.PaymentID != "" {
+		q = append(q, "tx_payment_id="+req.PaymentID)
+	}
+	if req.Amount > 0 {
+		q = append(q, "tx_amount="+walletrpc.XMRToDecimal(req.Amount))
+	}
+	if req.RecipientName != "" {
+		q = append(q, "recipient_name="+uriEscape(req.RecipientName))
+	}
+	if req.TxDescription != "" {
+		q = append(q, "tx_description="+uriEscape(req.TxDescription))
+	}
+	uri := "monero:" + req.Address
+	if len(q) > 0 {
+		uri += "?" + strings.Join(q, "&")
+	}
+	return walletrpc.H{"uri": uri}, nil
+}
+
+func (w *Wallet) parseURI(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		URI string `json:"uri"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(req.URI, "monero:") {
+		return nil, walletError(walletrpc.ErrWrongURI, "Error parsing URI: URI has wrong scheme (expected \"monero:\")")
+	}
+	rest := strings.TrimPrefix(req.URI, "monero:")
+	def := &walletrpc.URIDef{Address: rest}
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		def.Address = rest[:i]
+		q, err := url.ParseQuery(rest[i+1:])
+		if err != nil {
+			return nil, walletError(walletrpc.ErrWrongURI, "Error parsing URI: "+err.Error())
+		}
+		def.PaymentID = q.Get("tx_payment_id")
+		def.RecipientName = q.Get("recipient_name")
+		def.TxDescription = q.Get("tx_description")
+		if v := q.Get("tx_amount"); v != "" {
+			amount, ok := parseDecimal(v)
+			if !ok {
+				return nil, walletError(walletrpc.ErrWrongURI, "Error parsing URI: Invalid amount: "+v)
+			}
+			def.Amount = amount
+		}
+	}
+	if def.Address == "" {
+		return nil, walletError(walletrpc.ErrWrongURI, "Error parsing URI: Failed to parse address")
+	}
+	return walletrpc.H{"uri": def}, nil
+}
+
+// uriEscape percent-encodes a query value, with spaces as %20.
+func uriEscape(v string) string {
+	return strings.Replace(url.QueryEscape(v), "+", "%20", -1)
+}
+
+// parseDecimal parses an XMR amount like 1.5 into atomic units.
+func parseDecimal(v string) (uint64, bool) {
+	whole, frac := v, ""
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		whole, frac = v[:i], v[i+1:]
+	}
+	if len(frac) > 12 {
+		return 0, false
+	}
+	frac += strings.Repeat("0", 12-len(frac))
+	n, err := strconv.ParseUint(whole+frac, 10, 64)
+	return n, err == nil
+}
+
+func (w *Wallet) setTxNotes(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		TxIDs []string `json:"txids"`
+		Notes []string `json:"notes"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.TxIDs) != len(req.Notes) {
+		return nil, walletError(walletrpc.ErrUnknown, "Different amount of txids and notes")
+	}
+	for _, txid := range req.TxIDs {
+		if !isHex(txid, 64) {
+			return nil, walletError(walletrpc.ErrWrongTxID, "TX ID has invalid format")
+		}
+	}
+	for i, txid := range req.TxIDs {
+		w.notes[txid] = req.Notes[i]
+	}
+	return nil, nil
+}
+
+func (w *Wallet) getTxNotes(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		TxIDs []string `json:"txids"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	notes := make([]string, 0, len(req.TxIDs))
+	for _, txid := range req.TxIDs {
+		if !isHex(txid, 64) {
+			return nil, walletError(walletrpc.ErrWrongTxID, "TX ID has invalid format")
+		}
+		notes = append(notes, w.notes[txid])
+	}
+	return walletrpc.H{"notes": notes}, nil
+}
+
+func (w *Wallet) signature(address, data string) string {
+	return "SigV1" + w.hash("sign", address, data)
+}
+
+func (w *Wallet) sign(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Data string `json:"data"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	return walletrpc.H{"signature": w.signature(w.address, req.Data)}, nil
+}
+
+func (w *Wallet) verify(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Data      string `json:"data"`
+		Address   string `json:"address"`
+		Signature string `json:"signature"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Address == "" {
+		return nil, walletError(walletrpc.ErrWrongAddress, "Invalid address")
+	}
+	return walletrpc.H{"good": req.Signature == w.signature(req.Address, req.Data)}, nil
+}
+
+func (w *Wallet) keyImage(t walletrpc.IncTransfer) walletrpc.SignedKeyImage {
+	idx := strconv.FormatUint(t.GlobalIndex, 10)
+	return walletrpc.SignedKeyImage{
+		KeyImage:  w.hash("key_image", t.TxHash, idx),
+		Signature: w.hash("key_image_signature", t.TxHash, idx) + w.hash("key_image_signature2", t.TxHash, idx),
+	}
+}
+
+func (w *Wallet) exportKeyImages(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	images := make([]walletrpc.SignedKeyImage, 0, len(w.incoming))
+	for _, t := range w.incoming {
+		images = append(images, w.keyImage(t))
+	}
+	return walletrpc.H{"signed_key_images": images}, nil
+}
+
+func (w *Wallet) importKeyImages(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		SignedKeyImages []walletrpc.SignedKeyImage `json:"signed_key_images"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	resp := &walletrpc.ImportKeyImageResponse{Height: w.height}
+	for _, ski := range req.SignedKeyImages {
+		if !isHex(ski.KeyImage, 64) || !isHex(ski.Signature, 128) {
+			return nil, walletError(walletrpc.ErrWrongKeyImage, "failed to parse key image")
+		}
+		for _, t := range w.incoming {
+			if w.keyImage(t).KeyImage != ski.KeyImage {
+				continue
+			}
+			if t.Spent {
+				resp.Spent += t.Amount
+			} else {
+				resp.Unspent += t.Amount
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (w *Wallet) getAddressBook(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Entries []uint64 `json:"entries"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Entries) == 0 {
+		return walletrpc.H{"entries": w.addressBook}, nil
+	}
+	entries := make([]walletrpc.AddressBookEntry, 0, len(req.Entries))
+	for _, idx := range req.Entries {
+		if idx >= uint64(len(w.addressBook)) {
+			return nil, walletError(walletrpc.ErrWrongIndex, "Index out of range: "+strconv.FormatUint(idx, 10))
+		}
+		entries = append(entries, w.addressBook[idx])
+	}
+	return walletrpc.H{"entries": entries}, nil
+}
+
+func (w *Wallet) addAddressBook(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req walletrpc.AddressBookEntry
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Address == "" {
+		return nil, walletError(walletrpc.ErrWrongAddress, "WALLET_RPC_ERROR_CODE_WRONG_ADDRESS: "+req.Address)
+	}
+	if !validPaymentID(req.PaymentID) {
+		return nil, walletError(walletrpc.ErrWrongPaymentID, "Payment id has invalid format")
+	}
+	req.Index = uint64(len(w.addressBook))
+	w.addressBook = append(w.addressBook, req)
+	return walletrpc.H{"index": req.Index}, nil
+}
+
+func (w *Wallet) deleteAddressBook(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Index uint64 `json:"index"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Index >= uint64(len(w.addressBook)) {
+		return nil, walletError(walletrpc.ErrWrongIndex, "Index out of range: "+strconv.FormatUint(req.Index, 10))
+	}
+	// like monero-wallet-rpc, the following entries shift down
+	w.addressBook = append(w.addressBook[:req.Index], w.addressBook[req.Index+1:]...)
+	for i := range w.addressBook {
+		w.addressBook[i].Index = uint64(i)
+	}
+	return nil, nil
+}
+
+func (w *Wallet) startMining(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Threads uint `json:"threads_count"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Threads < 1 {
+		return nil, walletError(walletrpc.ErrUnknown, "Invalid number of threads")
+	}
+	return nil, nil
+}
+
+func (w *Wallet) getLanguages(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	return walletrpc.H{"languages": w.languages}, nil
+}
+
+func (w *Wallet) createWallet(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Filename string `json:"filename"`
+		Password string `json:"password"`
+		Language string `json:"language"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Filename == "" {
+		return nil, walletError(walletrpc.ErrUnknown, "Invalid filename")
+	}
+	if _, ok := w.files[req.Filename]; ok {
+		return nil, walletError(walletrpc.ErrWalletAlreadyExists, "Wallet already exists.")
+	}
+	known := false
+	for _, l := range w.languages {
+		known = known || l == req.Language
+	}
+	if !known {
+		return nil, walletError(walletrpc.ErrUnknown, "Unknown language: "+req.Language)
+	}
+	w.files[req.Filename] = req.Password
+	w.open = true
+	return nil, nil
+}
+
+func (w *Wallet) openWallet(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Filename string `json:"filename"`
+		Password string `json:"password"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	password, ok := w.files[req.Filename]
+	if !ok {
+		return nil, walletError(walletrpc.ErrUnknown, "Failed to open wallet")
+	}
+	if password != req.Password {
+		return nil, walletError(walletrpc.ErrInvalidPassword, "invalid password")
+	}
+	w.open = true
+	return nil, nil
+}
+
+// addressAt returns the address of a subaddress of an account.
+func (w *Wallet) addressAt(major, minor uint64) string {
+	if major == 0 && minor == 0 {
+		return w.address
+	}
+	return w.accounts[major].addresses[minor].address
+}
+
+// subaddress returns a new subaddress, 95 characters long like the real
+// ones.
+func (w *Wallet) subaddress() string {
+	return "8" + (w.nextHash("subaddress") + w.nextHash("subaddress"))[:94]
+}
+
+func (w *Wallet) createAccount(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Label string `json:"label"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	a := account{addresses: []subaddress{{address: w.subaddress(), label: req.Label}}}
+	w.accounts = append(w.accounts, a)
+	return walletrpc.H{"account_index": len(w.accounts) - 1, "address": a.addresses[0].address}, nil
+}
+
+func (w *Wallet) getAccounts(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Tag string `json:"tag"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if _, ok := w.accountTags[req.Tag]; req.Tag != "" && !ok {
+		return nil, walletError(walletrpc.ErrUnknown, "Tag "+req.Tag+" is unregistered.")
+	}
+	resp := &walletrpc.GetAccountsResponse{}
+	for i, a := range w.accounts {
+		if req.Tag != "" && a.tag != req.Tag {
+			continue
+		}
+		acc := walletrpc.Account{
+			AccountIndex: uint64(i),
+			BaseAddress:  w.addressAt(uint64(i), 0),
+			Label:        a.addresses[0].label,
+			Tag:          a.tag,
+		}
+		if i == 0 {
+			acc.Balance, acc.UnlockedBalance = w.balance, w.unlocked
+		}
+		resp.SubaddressAccounts = append(resp.SubaddressAccounts, acc)
+		resp.TotalBalance += acc.Balance
+		resp.TotalUnlockedBalance += acc.UnlockedBalance
+	}
+	return resp, nil
+}
+
+func (w *Wallet) labelAccount(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Index uint64 `json:"account_index"`
+		Label string `json:"label"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Index >= uint64(len(w.accounts)) {
+		return nil, walletError(walletrpc.ErrAccountIndexOutOfBounds, "account index is out of bound")
+	}
+	w.accounts[req.Index].addresses[0].label = req.Label
+	return nil, nil
+}
+
+func (w *Wallet) getAccountTags(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	names := make([]string, 0, len(w.accountTags))
+	for tag := range w.accountTags {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+	tags := make([]walletrpc.AccountTag, 0, len(names))
+	for _, tag := range names {
+		at := walletrpc.AccountTag{Tag: tag, Label: w.accountTags[tag], Accounts: []uint64{}}
+		for i, a := range w.accounts {
+			if a.tag == tag {
+				at.Accounts = append(at.Accounts, uint64(i))
+			}
+		}
+		tags = append(tags, at)
+	}
+	return walletrpc.H{"account_tags": tags}, nil
+}
+
+// setAccountTag tags the accounts, or untags them when tag is empty. Like
+// monero-wallet-rpc, tags stay registered once their accounts are
+// untagged.
+func (w *Wallet) setAccountTag(accounts []uint64, tag string) *walletrpc.WalletError {
+	for _, idx := range accounts {
+		if idx >= uint64(len(w.accounts)) {
+			return walletError(walletrpc.ErrUnknown, "Account index out of bound")
+		}
+	}
+	for _, idx := range accounts {
+		w.accounts[idx].tag = tag
+	}
+	if _, ok := w.accountTags[tag]; tag != "" && !ok {
+		w.accountTags[tag] = ""
+	}
+	return nil
+}
+
+func (w *Wallet) tagAccounts(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Tag      string   `json:"tag"`
+		Accounts []uint64 `json:"accounts"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	return nil, w.setAccountTag(req.Accounts, req.Tag)
+}
+
+func (w *Wallet) untagAccounts(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Accounts []uint64 `json:"accounts"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	return nil, w.setAccountTag(req.Accounts, "")
+}
+
+func (w *Wallet) setAccountTagDescription(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Tag         string `json:"tag"`
+		Description string `json:"description"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Tag == "" {
+		return nil, walletError(walletrpc.ErrUnknown, "Tag must not be empty")
+	}
+	if _, ok := w.accountTags[req.Tag]; !ok {
+		return nil, walletError(walletrpc.ErrUnknown, "Tag is unregistered")
+	}
+	w.accountTags[req.Tag] = req.Description
+	return nil, nil
+}
+
+// checkIndex checks that a subaddress exists, like monero-wallet-rpc.
+func (w *Wallet) checkIndex(major, minor uint64) *walletrpc.WalletError {
+	if major >= uint64(len(w.accounts)) {
+		return walletError(walletrpc.ErrAccountIndexOutOfBounds, "account index is out of bound")
+	}
+	if minor >= uint64(len(w.accounts[major].addresses)) {
+		return walletError(walletrpc.ErrAddressIndexOutOfBounds, "address index is out of bound")
+	}
+	return nil
+}
+
+func (w *Wallet) createAddress(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		AccountIndex uint64 `json:"account_index"`
+		Label        string `json:"label"`
+		Count        uint64 `json:"count"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	if req.Count > 64 {
+		return nil, walletError(walletrpc.ErrUnknown, "Count must be between 1 and 64.")
+	}
+	if err := w.checkIndex(req.AccountIndex, 0); err != nil {
+		return nil, err
+	}
+	a := &w.accounts[req.AccountIndex]
+	resp := &walletrpc.CreateAddressResponse{}
+	for i := uint64(0); i < req.Count; i++ {
+		sa := subaddress{address: w.subaddress(), label: req.Label}
+		a.addresses = append(a.addresses, sa)
+		resp.Addresses = append(resp.Addresses, sa.address)
+		resp.AddressIndices = append(resp.AddressIndices, uint64(len(a.addresses)-1))
+	}
+	resp.Address, resp.AddressIndex = resp.Addresses[0], resp.AddressIndices[0]
+	return resp, nil
+}
+
+func (w *Wallet) labelAddress(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Index walletrpc.SubaddressIndex `json:"index"`
+		Label string                    `json:"label"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if err := w.checkIndex(req.Index.Major, req.Index.Minor); err != nil {
+		return nil, err
+	}
+	w.accounts[req.Index.Major].addresses[req.Index.Minor].label = req.Label
+	return nil, nil
+}
+
+func (w *Wallet) getAddressIndex(params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	var req struct {
+		Address string `json:"address"`
+	}
+	if err := decodeParams(params, &req); err != nil {
+		return nil, err
+	}
+	if len(req.Address) != 95 {
+		return nil, walletError(walletrpc.ErrWrongAddress, "Invalid address")
+	}
+	for major, a := range w.accounts {
+		for minor := range a.addresses {
+			if w.addressAt(uint64(major), uint64(minor)) == req.Address {
+				return walletrpc.H{"index": walletrpc.SubaddressIndex{Major: uint64(major), Minor: uint64(minor)}}, nil
+			}
+		}
+	}
+	return nil, walletError(walletrpc.ErrWrongAddress, "Address doesn't belong to the wallet")
+}
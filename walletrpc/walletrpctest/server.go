@@ -0,0 +1,347 @@
+package walletrpctest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+// DigestRealm is the realm of the digest authentication challenges, the
+// same as monero-wallet-rpc.
+const DigestRealm = "monero-rpc"
+
+// ServerConfig configures a Server.
+type ServerConfig struct {
+	// Wallet is the model served. A NewWallet is used when nil.
+	Wallet *Wallet
+	// Username and Password enable HTTP digest authentication, like
+	// monero-wallet-rpc --rpc-login.
+	Username string
+	Password string
+	// TLS serves https with a self-signed certificate.
+	TLS bool
+}
+
+// Server is a fake monero-wallet-rpc listening on a local port. It serves
+// every method of walletrpc.Client from its Wallet on /json_rpc.
+type Server struct {
+	*httptest.Server
+	// Wallet is the model served. It can be edited at any time.
+	Wallet *Wallet
+
+	username string
+	password string
+
+	mu         sync.Mutex
+	nonce      string
+	seen       map[string]bool
+	challenges int
+	httpErrs   map[string]int
+}
+
+// NewServer starts a Server. Close it when done.
+func NewServer(cfg ServerConfig) *Server {
+	s := &Server{
+		Wallet:   cfg.Wallet,
+		username: cfg.Username,
+		password: cfg.Password,
+		httpErrs: make(map[string]int),
+	}
+	if s.Wallet == nil {
+		s.Wallet = NewWallet()
+	}
+	s.RotateNonce()
+	s.Server = httptest.NewUnstartedServer(s)
+	if cfg.TLS {
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+	return s
+}
+
+// Address returns the address of the JSON-RPC endpoint.
+func (s *Server) Address() string {
+	return s.URL + "/json_rpc"
+}
+
+// Config returns a walletrpc.Config to reach the server, with its
+// credentials and, with TLS, the fingerprint of its certificate.
+func (s *Server) Config() walletrpc.Config {
+	cfg := walletrpc.Config{
+		Address:  s.Address(),
+		Username: s.username,
+		Password: s.password,
+	}
+	if s.TLS != nil {
+		cfg.TLS = &walletrpc.TLSConfig{
+			Fingerprints: []string{walletrpc.CertificateFingerprint(s.Certificate().Raw)},
+		}
+	}
+	return cfg
+}
+
+// SetHTTPError makes the requests calling method (or any request when method
+// is empty) fail with the HTTP status, until ClearHTTPError is called.
+func (s *Server) SetHTTPError(method string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.httpErrs[method] = status
+}
+
+// ClearHTTPError removes the HTTP status set for method.
+func (s *Server) ClearHTTPError(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.httpErrs, method)
+}
+
+// RotateNonce replaces the digest nonce, so the next requests are answered
+// with a stale challenge.
+func (s *Server) RotateNonce() {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nonce = hex.EncodeToString(buf)
+	s.seen = make(map[string]bool)
+}
+
+// Challenges returns how many digest challenges were sent.
+func (s *Server) Challenges() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.challenges
+}
+
+// ServeHTTP serves the JSON-RPC endpoint.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/json_rpc" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.username != "" || s.password != "" {
+		if ok, stale := s.authorized(r); !ok {
+			s.challenge(w, stale)
+			return
+		}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status := s.httpError(body); status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	s.Wallet.ServeHTTP(w, r)
+}
+
+// httpError returns the HTTP status set for the methods of a request body.
+func (s *Server) httpError(body []byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.httpErrs) == 0 {
+		return 0
+	}
+	if status, ok := s.httpErrs[""]; ok {
+		return status
+	}
+	for _, req := range parseRequests(body) {
+		if status, ok := s.httpErrs[req.Method]; ok {
+			return status
+		}
+	}
+	return 0
+}
+
+func (s *Server) challenge(w http.ResponseWriter, stale bool) {
+	s.mu.Lock()
+	s.challenges++
+	nonce := s.nonce
+	s.mu.Unlock()
+	for _, alg := range []string{"MD5-sess", "MD5"} {
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Digest qop="auth",algorithm=%v,realm="%v",nonce="%v",stale=%v`, alg, DigestRealm, nonce, stale))
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	io.WriteString(w, "<html><head><title>Unauthorized Access</title></head><body><h1>401 Unauthorized</h1></body></html>")
+}
+
+// authorized checks the digest Authorization of r. stale is set when the
+// credentials are valid for an old nonce.
+func (s *Server) authorized(r *http.Request) (ok, stale bool) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		return false, false
+	}
+	p, err := parseAuthorization(auth[len("Digest "):])
+	if err != nil {
+		return false, false
+	}
+	if p["username"] != s.username || p["realm"] != DigestRealm || p["qop"] != "auth" || p["uri"] != r.URL.RequestURI() {
+		return false, false
+	}
+	var newHash func() hash.Hash
+	switch strings.ToUpper(p["algorithm"]) {
+	case "", "MD5", "MD5-SESS":
+		newHash = md5.New
+	case "SHA-256", "SHA-256-SESS":
+		newHash = sha256.New
+	default:
+		return false, false
+	}
+	hexhash := func(v string) string {
+		h := newHash()
+		io.WriteString(h, v)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	ha1 := hexhash(s.username + ":" + DigestRealm + ":" + s.password)
+	if strings.HasSuffix(strings.ToUpper(p["algorithm"]), "-SESS") {
+		ha1 = hexhash(ha1 + ":" + p["nonce"] + ":" + p["cnonce"])
+	}
+	ha2 := hexhash(r.Method + ":" + p["uri"])
+	if p["response"] != hexhash(ha1+":"+p["nonce"]+":"+p["nc"]+":"+p["cnonce"]+":auth:"+ha2) {
+		return false, false
+	}
+	if _, err := strconv.ParseUint(p["nc"], 16, 32); err != nil {
+		return false, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p["nonce"] != s.nonce {
+		return false, true
+	}
+	// reject replayed requests
+	key := p["cnonce"] + ":" + p["nc"]
+	if s.seen[key] {
+		return false, false
+	}
+	s.seen[key] = true
+	return true, false
+}
+
+// parseAuthorization parses the auth-params of a Digest Authorization
+// header (RFC 7235 and RFC 7616). It is written independently of the parser
+// of the walletrpc client on purpose, and is stricter: a header that does
+// not follow the grammar is rejected instead of read as well as possible,
+// so a regression of the client can't hide behind a bug both share.
+func parseAuthorization(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	sc := &authScanner{s: s}
+	for {
+		sc.skipSpace()
+		key := sc.token()
+		if key == "" {
+			return nil, fmt.Errorf("expected a parameter name at offset %d", sc.pos)
+		}
+		sc.skipSpace()
+		if !sc.consume('=') {
+			return nil, fmt.Errorf("expected = after %v", key)
+		}
+		sc.skipSpace()
+		var val string
+		if sc.consume('"') {
+			var err error
+			if val, err = sc.quotedString(); err != nil {
+				return nil, fmt.Errorf("%v: %v", key, err)
+			}
+		} else if val = sc.token(); val == "" {
+			return nil, fmt.Errorf("expected a value for %v", key)
+		}
+		key = strings.ToLower(key)
+		if _, dup := params[key]; dup {
+			return nil, fmt.Errorf("duplicate parameter %v", key)
+		}
+		params[key] = val
+		sc.skipSpace()
+		if sc.pos == len(sc.s) {
+			return params, nil
+		}
+		if !sc.consume(',') {
+			return nil, fmt.Errorf("expected a comma at offset %d", sc.pos)
+		}
+	}
+}
+
+// authScanner reads the tokens of an Authorization header.
+type authScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *authScanner) skipSpace() {
+	for sc.pos < len(sc.s) && (sc.s[sc.pos] == ' ' || sc.s[sc.pos] == '\t') {
+		sc.pos++
+	}
+}
+
+func (sc *authScanner) consume(c byte) bool {
+	if sc.pos < len(sc.s) && sc.s[sc.pos] == c {
+		sc.pos++
+		return true
+	}
+	return false
+}
+
+// token reads a RFC 7230 token.
+func (sc *authScanner) token() string {
+	start := sc.pos
+	for sc.pos < len(sc.s) && isTokenChar(sc.s[sc.pos]) {
+		sc.pos++
+	}
+	return sc.s[start:sc.pos]
+}
+
+// quotedString reads the rest of a RFC 7230 quoted-string, after the
+// opening quote.
+func (sc *authScanner) quotedString() (string, error) {
+	var b strings.Builder
+	for sc.pos < len(sc.s) {
+		c := sc.s[sc.pos]
+		sc.pos++
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\':
+			if sc.pos == len(sc.s) {
+				return "", errors.New("unterminated quoted-pair")
+			}
+			c = sc.s[sc.pos]
+			sc.pos++
+			if c != '\t' && (c < 0x20 || c == 0x7f) {
+				return "", fmt.Errorf("invalid quoted-pair %q", c)
+			}
+			b.WriteByte(c)
+		case c == '\t' || (c >= 0x20 && c != 0x7f):
+			b.WriteByte(c)
+		default:
+			return "", fmt.Errorf("invalid character %q in quoted-string", c)
+		}
+	}
+	return "", errors.New("unterminated quoted-string")
+}
+
+func isTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
@@ -0,0 +1,115 @@
+package walletrpctest
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gabstv/go-monero/walletrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	sv := NewServer(ServerConfig{
+		Username: "john",
+		Password: "doe",
+	})
+	defer sv.Close()
+	sv.Wallet.AddIncomingTransfer(walletrpc.Transfer{Amount: 2e12})
+
+	cl := walletrpc.New(sv.Config())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			balance, _, err := cl.GetBalance()
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(2e12), balance)
+		}()
+	}
+	wg.Wait()
+
+	_, err := cl.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sv.Wallet.Transfers(), 2)
+
+	// a stale nonce is renewed
+	challenges := sv.Challenges()
+	sv.RotateNonce()
+	_, err = cl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, challenges+1, sv.Challenges())
+
+	// wrong credentials
+	cfg := sv.Config()
+	cfg.Password = "wrong"
+	_, err = walletrpc.New(cfg).GetHeight()
+	var herr *walletrpc.HTTPError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.Equal(t, http.StatusUnauthorized, herr.StatusCode)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	sv := NewServer(ServerConfig{TLS: true})
+	defer sv.Close()
+	cl := walletrpc.New(sv.Config())
+
+	height, err := cl.GetHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), height)
+
+	sv.SetHTTPError("getheight", http.StatusInternalServerError)
+	_, err = cl.GetHeight()
+	var herr *walletrpc.HTTPError
+	if assert.True(t, errors.As(err, &herr)) {
+		assert.Equal(t, http.StatusInternalServerError, herr.StatusCode)
+	}
+	_, err = cl.GetAddress()
+	assert.NoError(t, err)
+	sv.ClearHTTPError("getheight")
+
+	sv.Wallet.SetError("getheight", &walletrpc.WalletError{Code: walletrpc.ErrNoDaemonConnection})
+	_, err = cl.GetHeight()
+	assert.True(t, errors.Is(err, walletrpc.ErrNoDaemonConnection))
+
+	resp, err := sv.Client().Get(sv.URL + "/json_rpc")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+	resp, err = sv.Client().Get(sv.URL + "/other")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestParseAuthorization(t *testing.T) {
+	p, err := parseAuthorization(`username="jöhn", realm="a, \"b\"", nc=00000001 ,qop=auth`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"username": "jöhn",
+		"realm":    `a, "b"`,
+		"nc":       "00000001",
+		"qop":      "auth",
+	}, p)
+
+	for _, bad := range []string{
+		``,
+		`username`,
+		`username="john`,
+		`username="jo` + "\x01" + `hn"`,
+		`username=john realm=x`,
+		`username=john, username=jane`,
+		`username=, realm=x`,
+		`username="john",`,
+	} {
+		_, err := parseAuthorization(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,446 @@
+// Package walletrpctest provides fakes of monero-wallet-rpc to test code that
+// depends on walletrpc.Client, and a conformance suite for implementations
+// of walletrpc.Client.
+package walletrpctest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gabstv/go-monero/walletrpc"
+)
+
+// DefaultAddress is the address of a new Wallet.
+const DefaultAddress = "45eoXYNHC4LcL2Hh42T9FMPTmZHyDEwDbgfBEuNj3RZUek8A4og4KiCfVL6ZmvHBfCALnggWtHH7QHF8426yRayLQq7MLf5"
+
+// DefaultFee is the fee charged by a new Wallet for each transaction.
+const DefaultFee uint64 = 30000000
+
+// JSON-RPC 2.0 error codes.
+const (
+	errParse          walletrpc.ErrorCode = -32700
+	errInvalidRequest walletrpc.ErrorCode = -32600
+	errMethodNotFound walletrpc.ErrorCode = -32601
+	errInvalidParams  walletrpc.ErrorCode = -32602
+)
+
+type clientContext = walletrpc.ClientContext
+
+// Wallet is an in-memory fake of monero-wallet-rpc. It implements
+// walletrpc.ClientContext: calls are encoded as JSON-RPC requests and served
+// by the wallet model without any network, so they go through the same
+// decoding and error handling as a real client. Wallet is also an
+// http.Handler and an http.RoundTripper, to be used behind a real
+// walletrpc client (see Config.Transport) or an HTTP server.
+//
+// The model holds a single wallet. Seed it with SetBalance,
+// AddIncomingTransfer, AddPayment, AddAddressBookEntry, SetTxNote and
+// MarkAddressUsed, and make methods fail with SetError and FailNext. Wallet
+// is safe for concurrent use.
+type Wallet struct {
+	clientContext
+
+	mu          sync.Mutex
+	address     string
+	height      uint64
+	fee         uint64
+	balance     uint64
+	unlocked    uint64
+	transfers   []walletrpc.Transfer
+	incoming    []walletrpc.IncTransfer
+	addressBook []walletrpc.AddressBookEntry
+	accounts    []account
+	accountTags map[string]string
+	notes       map[string]string
+	keys        map[walletrpc.QueryKeyType]string
+	integrated  map[string][2]string
+	files       map[string]string
+	open        bool
+	languages   []string
+	seq         uint64
+	errs        map[string]*walletrpc.WalletError
+	failNext    map[string][]*walletrpc.WalletError
+	calls       map[string]int
+}
+
+var _ walletrpc.ClientContext = (*Wallet)(nil)
+
+// NewWallet returns an open Wallet with the DefaultAddress, no funds and a
+// height of 1.
+func NewWallet() *Wallet {
+	w := &Wallet{
+		address:    DefaultAddress,
+		height:     1,
+		fee:        DefaultFee,
+		notes:      make(map[string]string),
+		integrated: make(map[string][2]string),
+		files:      make(map[string]string),
+		open:       true,
+		languages: []string{
+			"Deutsch", "English", "Español", "Français", "Italiano",
+			"Nederlands", "Português", "русский язык", "日本語",
+			"简体中文 (中国)", "Esperanto", "Lojban",
+		},
+		errs:     make(map[string]*walletrpc.WalletError),
+		failNext: make(map[string][]*walletrpc.WalletError),
+		calls:    make(map[string]int),
+	}
+	w.keys = map[walletrpc.QueryKeyType]string{
+		walletrpc.QueryKeyView:     w.hash("view_key"),
+		walletrpc.QueryKeySpend:    w.hash("spend_key"),
+		walletrpc.QueryKeyMnemonic: "sequence atlas unveil summon pebbles tuesday beer rudely snake rockets different fuselage woven tagged bested dented vegan hover rapid fawns obvious muppet randomly seasons randomly",
+	}
+	w.accounts = []account{{addresses: []subaddress{{label: "Primary account"}}}}
+	w.accountTags = make(map[string]string)
+	w.clientContext = walletrpc.NewClientContext(walletrpc.Config{
+		Address:   "http://walletrpctest/json_rpc",
+		Transport: w,
+	})
+	return w
+}
+
+// SetAddress sets the address of the wallet.
+func (w *Wallet) SetAddress(address string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.address = address
+}
+
+// SetHeight sets the blockchain height of the wallet.
+func (w *Wallet) SetHeight(height uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.height = height
+}
+
+// SetFee sets the fee charged for each transaction.
+func (w *Wallet) SetFee(fee uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fee = fee
+}
+
+// SetBalance sets the total and unlocked balance.
+func (w *Wallet) SetBalance(balance, unlocked uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.balance, w.unlocked = balance, unlocked
+}
+
+// Balance returns the total and unlocked balance.
+func (w *Wallet) Balance() (balance, unlocked uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.balance, w.unlocked
+}
+
+// SetKey sets the key returned by QueryKey.
+func (w *Wallet) SetKey(keytype walletrpc.QueryKeyType, key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.keys[keytype] = key
+}
+
+// AddWalletFile adds a wallet file that OpenWallet can open.
+func (w *Wallet) AddWalletFile(filename, password string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[filename] = password
+}
+
+// AddIncomingTransfer adds a transfer received by the wallet and returns its
+// txid. The transfer is of type "in" and credits the balance and unlocked
+// balance, unless its Type is "pool". An empty TxID is generated and a zero
+// Height defaults to the wallet height. Transfers with a PaymentID are also
+// returned by GetPayments and GetBulkPayments.
+func (w *Wallet) AddIncomingTransfer(t walletrpc.Transfer) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t.TxID == "" {
+		t.TxID = w.nextHash("tx")
+	}
+	if t.Height == 0 && t.Type != "pool" {
+		t.Height = w.height
+	}
+	if t.Timestamp == 0 {
+		t.Timestamp = uint64(time.Now().Unix())
+	}
+	if t.Type != "pool" {
+		t.Type = "in"
+		w.balance += t.Amount
+		w.unlocked += t.Amount
+		w.incoming = append(w.incoming, walletrpc.IncTransfer{
+			Amount:      t.Amount,
+			GlobalIndex: uint64(len(w.incoming)),
+			TxHash:      t.TxID,
+			TxSize:      1500,
+		})
+	}
+	w.transfers = append(w.transfers, t)
+	return t.TxID
+}
+
+// AddPayment adds an incoming transfer for p (see AddIncomingTransfer) and
+// returns its txid.
+func (w *Wallet) AddPayment(p walletrpc.Payment) string {
+	return w.AddIncomingTransfer(walletrpc.Transfer{
+		TxID:      p.TxHash,
+		PaymentID: p.PaymentID,
+		Height:    p.BlockHeight,
+		Amount:    p.Amount,
+	})
+}
+
+// AddAddressBookEntry adds an entry to the address book and returns its
+// index.
+func (w *Wallet) AddAddressBookEntry(entry walletrpc.AddressBookEntry) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry.Index = uint64(len(w.addressBook))
+	w.addressBook = append(w.addressBook, entry)
+	return entry.Index
+}
+
+// SetTxNote sets the note of a transaction.
+func (w *Wallet) SetTxNote(txid, note string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.notes[txid] = note
+}
+
+// MarkAddressUsed marks a subaddress as having received funds. Subaddresses
+// that do not exist are ignored.
+func (w *Wallet) MarkAddressUsed(index walletrpc.SubaddressIndex) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if index.Major < uint64(len(w.accounts)) && index.Minor < uint64(len(w.accounts[index.Major].addresses)) {
+		w.accounts[index.Major].addresses[index.Minor].used = true
+	}
+}
+
+// Transfers returns every transfer of the wallet, including the ones sent
+// with Transfer, TransferSplit and SweepAll.
+func (w *Wallet) Transfers() []walletrpc.Transfer {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]walletrpc.Transfer(nil), w.transfers...)
+}
+
+// SetError makes every call of method (a monero-wallet-rpc method name such
+// as "transfer") fail with err, until ClearError is called. An empty
+// Message is filled from the code.
+func (w *Wallet) SetError(method string, err *walletrpc.WalletError) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errs[method] = withMessage(err)
+}
+
+// FailNext makes the next call of method fail with err. Errors queued for
+// the same method are returned in order.
+func (w *Wallet) FailNext(method string, err *walletrpc.WalletError) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.failNext[method] = append(w.failNext[method], withMessage(err))
+}
+
+// ClearError removes the errors set for method by SetError and FailNext.
+func (w *Wallet) ClearError(method string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.errs, method)
+	delete(w.failNext, method)
+}
+
+// Calls returns how many times method was called, including the calls that
+// failed.
+func (w *Wallet) Calls(method string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.calls[method]
+}
+
+func withMessage(err *walletrpc.WalletError) *walletrpc.WalletError {
+	if err.Message == "" {
+		cp := *err
+		cp.Message = err.Code.Error()
+		return &cp
+	}
+	return err
+}
+
+// account is a subaddress account. The funds of the wallet belong to
+// account 0, whose base address is the wallet address.
+type account struct {
+	tag string
+	// addresses[0] is the base address, labelled with the account label
+	addresses []subaddress
+}
+
+type subaddress struct {
+	address string
+	label   string
+	used    bool
+}
+
+// request is a JSON-RPC 2.0 request.
+type request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// response is a JSON-RPC 2.0 response.
+type response struct {
+	Version string                 `json:"jsonrpc"`
+	ID      json.RawMessage        `json:"id"`
+	Result  interface{}            `json:"result,omitempty"`
+	Error   *walletrpc.WalletError `json:"error,omitempty"`
+}
+
+// ServeHTTP serves JSON-RPC 2.0 requests, including batches, on any path.
+func (w *Wallet) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var out interface{}
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []request
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			out = parseError()
+		} else {
+			resps := make([]*response, 0, len(reqs))
+			for i := range reqs {
+				resps = append(resps, w.serve(&reqs[i]))
+			}
+			out = resps
+		}
+	} else {
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			out = parseError()
+		} else {
+			out = w.serve(&req)
+		}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(out)
+}
+
+func parseError() *response {
+	return &response{
+		Version: "2.0",
+		ID:      json.RawMessage("null"),
+		Error:   &walletrpc.WalletError{Code: errParse, Message: "Parse error"},
+	}
+}
+
+// RoundTrip serves the request in memory, so a Wallet can be used as the
+// Transport of a walletrpc.Config.
+func (w *Wallet) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := r.Context().Err(); err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, r)
+	if r.Body != nil {
+		r.Body.Close()
+	}
+	resp := rec.Result()
+	resp.Request = r
+	return resp, nil
+}
+
+func (w *Wallet) serve(req *request) *response {
+	resp := &response{Version: "2.0", ID: req.ID}
+	if len(resp.ID) == 0 {
+		resp.ID = json.RawMessage("null")
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		resp.Error = &walletrpc.WalletError{Code: errInvalidRequest, Message: "Invalid Request"}
+		return resp
+	}
+	result, err := w.handle(req.Method, req.Params)
+	if err != nil {
+		resp.Error = err
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+// handle runs method against the wallet model. It is shared by every fake of
+// this package.
+func (w *Wallet) handle(method string, params json.RawMessage) (interface{}, *walletrpc.WalletError) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.calls[method]++
+	if q := w.failNext[method]; len(q) > 0 {
+		w.failNext[method] = q[1:]
+		return nil, q[0]
+	}
+	if err := w.errs[method]; err != nil {
+		return nil, err
+	}
+	h, ok := handlers[method]
+	if !ok {
+		return nil, &walletrpc.WalletError{Code: errMethodNotFound, Message: "Method not found"}
+	}
+	if !w.open && !walletless[method] {
+		return nil, walletError(walletrpc.ErrNotOpen, "No wallet file")
+	}
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+	result, err := h(w, params)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return result, nil
+}
+
+func walletError(code walletrpc.ErrorCode, message string) *walletrpc.WalletError {
+	return &walletrpc.WalletError{Code: code, Message: message}
+}
+
+// decodeParams decodes the params of a request into v.
+func decodeParams(params json.RawMessage, v interface{}) *walletrpc.WalletError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return walletError(errInvalidParams, "Invalid params: "+err.Error())
+	}
+	return nil
+}
+
+// hash returns a deterministic 64 character hex string derived from parts.
+func (w *Wallet) hash(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// nextHash returns a new unique hash.
+func (w *Wallet) nextHash(kind string) string {
+	w.seq++
+	return w.hash(kind, w.address, strconv.FormatUint(w.seq, 10))
+}
@@ -0,0 +1,189 @@
+package walletrpctest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gabstv/go-monero/walletrpc"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPaymentID = "4279257e0a20608e"
+
+func TestWalletTransfer(t *testing.T) {
+	w := NewWallet()
+	w.SetHeight(100)
+	txid := w.AddIncomingTransfer(walletrpc.Transfer{Amount: 5e12})
+
+	balance, unlocked, err := w.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5e12), balance)
+	assert.Equal(t, uint64(5e12), unlocked)
+
+	resp, err := w.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+		GetTxKey:     true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultFee, resp.Fee)
+	assert.Len(t, resp.TxHash, 64)
+	assert.Len(t, resp.TxKey, 64)
+
+	balance, unlocked, err = w.GetBalance()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4e12)-DefaultFee, balance)
+	assert.Equal(t, uint64(4e12)-DefaultFee, unlocked)
+
+	transfers, err := w.GetTransfers(walletrpc.GetTransfersRequest{In: true, Out: true})
+	assert.NoError(t, err)
+	if assert.Len(t, transfers.In, 1) && assert.Len(t, transfers.Out, 1) {
+		assert.Equal(t, txid, transfers.In[0].TxID)
+		assert.Equal(t, resp.TxHash, transfers.Out[0].TxID)
+		assert.Equal(t, uint64(1e12), transfers.Out[0].Amount)
+		assert.Equal(t, "out", transfers.Out[0].Type)
+	}
+	tr, err := w.GetTransferByTxID(resp.TxHash)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(100), tr.Height)
+
+	_, err = w.Transfer(walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 10e12, Address: DefaultAddress}},
+	})
+	assert.True(t, errors.Is(err, walletrpc.ErrNotEnoughMoney))
+
+	w.SetBalance(5e12, 0)
+	_, err = w.SweepAll(walletrpc.SweepAllRequest{Address: DefaultAddress})
+	assert.True(t, errors.Is(err, walletrpc.ErrTxNotPossible))
+	assert.Equal(t, 3, w.Calls("transfer")+w.Calls("sweep_all"))
+}
+
+func TestWalletSeed(t *testing.T) {
+	w := NewWallet()
+	w.SetHeight(10)
+	txid := w.AddPayment(walletrpc.Payment{PaymentID: testPaymentID, Amount: 2e12, BlockHeight: 5})
+	w.AddPayment(walletrpc.Payment{PaymentID: testPaymentID, Amount: 3e12, BlockHeight: 8})
+	w.SetTxNote(txid, "first")
+	w.AddAddressBookEntry(walletrpc.AddressBookEntry{Address: DefaultAddress, Description: "self"})
+
+	payments, err := w.GetPayments(testPaymentID)
+	assert.NoError(t, err)
+	assert.Len(t, payments, 2)
+	payments, err = w.GetBulkPayments([]string{testPaymentID}, 6)
+	assert.NoError(t, err)
+	if assert.Len(t, payments, 1) {
+		assert.Equal(t, uint64(3e12), payments[0].Amount)
+	}
+	_, err = w.GetPayments("nothex")
+	assert.True(t, errors.Is(err, walletrpc.ErrWrongPaymentID))
+
+	notes, err := w.GetTxNotes([]string{txid})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first"}, notes)
+
+	entries, err := w.GetAddressBook(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "self", entries[0].Description)
+	}
+	idx, err := w.AddAddressBook(walletrpc.AddressBookEntry{Address: DefaultAddress, Description: "again"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), idx)
+	assert.NoError(t, w.DeleteAddressBook(0))
+	entries, err = w.GetAddressBook([]uint64{0})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "again", entries[0].Description)
+	}
+	_, err = w.GetAddressBook([]uint64{1})
+	assert.True(t, errors.Is(err, walletrpc.ErrWrongIndex))
+
+	incoming, err := w.IncomingTransfers(walletrpc.TransferAvailable)
+	assert.NoError(t, err)
+	assert.Len(t, incoming, 2)
+	images, err := w.ExportKeyImages()
+	assert.NoError(t, err)
+	res, err := w.ImportKeyImages(images)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5e12), res.Unspent)
+}
+
+func TestWalletMisc(t *testing.T) {
+	w := NewWallet()
+
+	integrated, err := w.MakeIntegratedAddress(testPaymentID)
+	assert.NoError(t, err)
+	assert.Len(t, integrated, 106)
+	pid, addr, err := w.SplitIntegratedAddress(integrated)
+	assert.NoError(t, err)
+	assert.Equal(t, testPaymentID, pid)
+	assert.Equal(t, DefaultAddress, addr)
+
+	uri, err := w.MakeURI(walletrpc.URIDef{Address: DefaultAddress, Amount: 15e11, TxDescription: "coffee & cake"})
+	assert.NoError(t, err)
+	parsed, err := w.ParseURI(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15e11), parsed.Amount)
+	assert.Equal(t, "coffee & cake", parsed.TxDescription)
+
+	sig, err := w.Sign("hello")
+	assert.NoError(t, err)
+	good, err := w.Verify("hello", DefaultAddress, sig)
+	assert.NoError(t, err)
+	assert.True(t, good)
+	good, err = w.Verify("hellO", DefaultAddress, sig)
+	assert.NoError(t, err)
+	assert.False(t, good)
+
+	assert.NoError(t, w.StopWallet())
+	_, err = w.GetAddress()
+	assert.True(t, errors.Is(err, walletrpc.ErrNotOpen))
+	assert.NoError(t, w.CreateWallet("test", "secret", "English"))
+	assert.True(t, errors.Is(w.CreateWallet("test", "secret", "English"), walletrpc.ErrWalletAlreadyExists))
+	assert.True(t, errors.Is(w.OpenWallet("test", "wrong"), walletrpc.ErrInvalidPassword))
+	assert.NoError(t, w.OpenWallet("test", "secret"))
+
+	var res struct {
+		Version uint64 `json:"version"`
+	}
+	err = w.Call("get_version", nil, &res)
+	var werr *walletrpc.WalletError
+	assert.True(t, errors.As(err, &werr))
+	assert.Equal(t, walletrpc.ErrorCode(-32601), werr.Code)
+}
+
+func TestWalletErrors(t *testing.T) {
+	w := NewWallet()
+	w.AddIncomingTransfer(walletrpc.Transfer{Amount: 5e12})
+
+	w.FailNext("getbalance", &walletrpc.WalletError{Code: walletrpc.ErrDaemonIsBusy})
+	_, _, err := w.GetBalance()
+	assert.True(t, errors.Is(err, walletrpc.ErrDaemonIsBusy))
+	_, _, err = w.GetBalance()
+	assert.NoError(t, err)
+
+	w.SetError("transfer", &walletrpc.WalletError{Code: walletrpc.ErrNoDaemonConnection, Message: "no connection to daemon"})
+	req := walletrpc.TransferRequest{
+		Destinations: []walletrpc.Destination{{Amount: 1e12, Address: DefaultAddress}},
+	}
+	for i := 0; i < 2; i++ {
+		_, err = w.Transfer(req)
+		assert.EqualError(t, err, "-38: no connection to daemon")
+	}
+	balance, _ := w.Balance()
+	assert.Equal(t, uint64(5e12), balance)
+
+	w.ClearError("transfer")
+	_, err = w.Transfer(req)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, w.Calls("transfer"))
+
+	// batches are served too
+	b := w.NewBatch()
+	var height uint64
+	var address string
+	b.GetHeight(&height)
+	b.GetAddress(&address)
+	assert.NoError(t, b.Send())
+	assert.Equal(t, uint64(1), height)
+	assert.Equal(t, DefaultAddress, address)
+}